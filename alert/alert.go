@@ -0,0 +1,146 @@
+/*
+Package alert sends alerts about failed imports and updates to webhooks
+(e.g. Slack or PagerDuty).
+*/
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"text/template"
+	"time"
+
+	"github.com/omniscale/imposm3/config"
+	"github.com/omniscale/imposm3/log"
+	"github.com/pkg/errors"
+)
+
+type Event string
+
+const (
+	// Failed is sent if an import, diff or run exits with a fatal error.
+	Failed = Event("failed")
+	// DiffErrors is sent if too many consecutive diff imports failed.
+	DiffErrors = Event("diff_errors")
+	// ReplicationLag is sent if the last imported diff is too old.
+	ReplicationLag = Event("replication_lag")
+)
+
+// Alert is passed to the hook templates, or sent as JSON to hooks
+// without template.
+type Alert struct {
+	Event    Event     `json:"event"`
+	Message  string    `json:"message"`
+	Hostname string    `json:"hostname"`
+	Time     time.Time `json:"time"`
+}
+
+type hook struct {
+	url    string
+	tmpl   *template.Template
+	events map[Event]bool
+}
+
+type Notifier struct {
+	hooks  []hook
+	client *http.Client
+}
+
+var funcs = template.FuncMap{
+	// json encodes a value as JSON, e.g. to quote messages in JSON
+	// templates: {"text": {{json .Message}}}
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// New creates a Notifier for all configured hooks. It returns an error if a
+// template is invalid.
+func New(conf config.Alerts) (*Notifier, error) {
+	n := &Notifier{client: &http.Client{Timeout: 10 * time.Second}}
+	for i, h := range conf.Hooks {
+		if h.URL == "" {
+			return nil, errors.Errorf("missing url for alert hook #%d", i)
+		}
+		hk := hook{url: h.URL}
+		if h.Template != "" {
+			tmpl, err := template.New(h.URL).Funcs(funcs).Parse(h.Template)
+			if err != nil {
+				return nil, errors.Wrapf(err, "parsing template for alert hook %s", h.URL)
+			}
+			hk.tmpl = tmpl
+		}
+		if len(h.Events) > 0 {
+			hk.events = make(map[Event]bool)
+			for _, e := range h.Events {
+				hk.events[Event(e)] = true
+			}
+		}
+		n.hooks = append(n.hooks, hk)
+	}
+	return n, nil
+}
+
+// Send sends an alert for event to all hooks that are configured for this
+// event. Errors are only logged, as alerts are sent while handling other
+// errors.
+func (n *Notifier) Send(event Event, msg string) {
+	hostname, _ := os.Hostname()
+	a := Alert{
+		Event:    event,
+		Message:  msg,
+		Hostname: hostname,
+		Time:     time.Now(),
+	}
+	for _, h := range n.hooks {
+		if h.events != nil && !h.events[event] {
+			continue
+		}
+		if err := n.send(h, a); err != nil {
+			log.Printf("[warn] Sending %s alert to %s: %s", event, h.url, err)
+		}
+	}
+}
+
+func (n *Notifier) send(h hook, a Alert) error {
+	body, err := render(h, a)
+	if err != nil {
+		return err
+	}
+	resp, err := n.client.Post(h.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errors.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+func render(h hook, a Alert) ([]byte, error) {
+	if h.tmpl == nil {
+		return json.Marshal(a)
+	}
+	buf := &bytes.Buffer{}
+	if err := h.tmpl.Execute(buf, a); err != nil {
+		return nil, errors.Wrap(err, "rendering template")
+	}
+	return buf.Bytes(), nil
+}
+
+// SendOnFatal creates a Notifier for conf that sends a Failed alert if the
+// process exits with log.Fatal. It exits if conf is invalid.
+func SendOnFatal(conf config.Alerts) *Notifier {
+	n, err := New(conf)
+	if err != nil {
+		log.Fatal("[error] ", err)
+	}
+	if len(n.hooks) > 0 {
+		log.OnFatal(func(msg string) { n.Send(Failed, msg) })
+	}
+	return n
+}
@@ -0,0 +1,53 @@
+package alert
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/omniscale/imposm3/config"
+)
+
+func TestNotifier_Send(t *testing.T) {
+	var bodies []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, r.URL.Path+" "+string(b))
+	}))
+	defer ts.Close()
+
+	n, err := New(config.Alerts{Hooks: []config.AlertHook{
+		{URL: ts.URL + "/slack", Template: `{"text": {{json .Message}}}`},
+		{URL: ts.URL + "/lag", Template: `{{.Event}}`, Events: []string{"replication_lag"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n.Send(Failed, `import "foo" failed`)
+	n.Send(ReplicationLag, "lag")
+
+	expected := []string{
+		`/slack {"text": "import \"foo\" failed"}`,
+		`/slack {"text": "lag"}`,
+		`/lag replication_lag`,
+	}
+	if len(bodies) != len(expected) {
+		t.Fatalf("unexpected requests: %q", bodies)
+	}
+	for i := range expected {
+		if bodies[i] != expected[i] {
+			t.Errorf("%q != %q", bodies[i], expected[i])
+		}
+	}
+}
+
+func TestNew_InvalidTemplate(t *testing.T) {
+	_, err := New(config.Alerts{Hooks: []config.AlertHook{
+		{URL: "http://localhost/", Template: `{{.Message`},
+	}})
+	if err == nil {
+		t.Error("expected error for invalid template")
+	}
+}
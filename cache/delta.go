@@ -7,6 +7,7 @@ import (
 
 	osm "github.com/omniscale/go-osm"
 	"github.com/omniscale/imposm3/cache/binary"
+	"github.com/pkg/errors"
 )
 
 type byID []osm.Node
@@ -72,6 +73,8 @@ func (b *coordsBunch) PutCoords(nodes []osm.Node) {
 	sort.Sort(byID(b.coords))
 }
 
+var errReadOnly = errors.New("coords cache is read-only")
+
 type DeltaCoordsCache struct {
 	cache
 	lruList      *list.List
@@ -84,8 +87,25 @@ type DeltaCoordsCache struct {
 }
 
 func newDeltaCoordsCache(path string) (*DeltaCoordsCache, error) {
+	return openDeltaCoordsCache(path, false)
+}
+
+// newReadOnlyDeltaCoordsCache opens the existing coords cache in path
+// without modifying it. LevelDB still locks the cache, so it can not be
+// opened while another process (e.g. imposm run) uses it.
+func newReadOnlyDeltaCoordsCache(path string) (*DeltaCoordsCache, error) {
+	c, err := openDeltaCoordsCache(path, true)
+	if err != nil {
+		return nil, errors.Wrapf(err, "opening coords cache %s read-only (is it used by another process?)", path)
+	}
+	return c, nil
+}
+
+func openDeltaCoordsCache(path string, readOnly bool) (*DeltaCoordsCache, error) {
 	coordsCache := DeltaCoordsCache{}
 	coordsCache.options = &globalCacheOptions.Coords.cacheOptions
+	coordsCache.cache.readOnly = readOnly
+	coordsCache.readOnly = readOnly
 	err := coordsCache.open(path)
 	if err != nil {
 		return nil, err
@@ -148,6 +168,9 @@ func (c *DeltaCoordsCache) GetCoord(id int64) (*osm.Node, error) {
 }
 
 func (c *DeltaCoordsCache) DeleteCoord(id int64) error {
+	if c.readOnly {
+		return errReadOnly
+	}
 	bunchID := c.getBunchID(id)
 	bunch, err := c.getBunch(bunchID)
 	if err != nil {
@@ -213,6 +236,9 @@ func removeSkippedNodes(nodes []osm.Node) []osm.Node {
 // PutCoords puts nodes into cache.
 // nodes need to be sorted by ID.
 func (c *DeltaCoordsCache) PutCoords(nodes []osm.Node) error {
+	if c.readOnly {
+		return errReadOnly
+	}
 	var start, currentBunchID int64
 	nodes = removeSkippedNodes(nodes)
 	if len(nodes) == 0 {
@@ -4,6 +4,7 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"sort"
 	"testing"
 
@@ -156,6 +157,39 @@ func deleteAndCheck(t *testing.T, cache *DeltaCoordsCache, id int64) {
 	}
 }
 
+func TestReadOnlyDeltaCoords(t *testing.T) {
+	cacheDir, _ := ioutil.TempDir("", "imposm_test")
+	defer os.RemoveAll(cacheDir)
+
+	if _, err := newReadOnlyDeltaCoordsCache(filepath.Join(cacheDir, "missing")); err == nil {
+		t.Error("opened missing cache")
+	}
+
+	cache, err := newDeltaCoordsCache(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	insertAndCheck(t, cache, 1, 10, 20)
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err = newReadOnlyDeltaCoordsCache(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+	if node, err := cache.GetCoord(1); err != nil || node.Long != 10 || node.Lat != 20 {
+		t.Errorf("unexpected coord %v %v", node, err)
+	}
+	if err := cache.PutCoords([]osm.Node{mknode(2)}); err != errReadOnly {
+		t.Error("expected read-only error, got", err)
+	}
+	if err := cache.DeleteCoord(1); err != errReadOnly {
+		t.Error("expected read-only error, got", err)
+	}
+}
+
 func TestSingleUpdate(t *testing.T) {
 	cacheDir, _ := ioutil.TempDir("", "imposm_test")
 	defer os.RemoveAll(cacheDir)
@@ -23,6 +23,8 @@ type OSMCache struct {
 	Nodes     *NodesCache
 	Relations *RelationsCache
 	opened    bool
+	// coordsDir is the coords cache of another cache directory, if set
+	coordsDir string
 }
 
 func (c *OSMCache) Close() {
@@ -49,12 +51,29 @@ func NewOSMCache(dir string) *OSMCache {
 	return cache
 }
 
+// SetCoordsDir uses the existing coords cache in dir (e.g. the coords
+// directory of a previous import) instead of the coords of this cache.
+// These coords are opened read-only and are not removed with Remove.
+func (c *OSMCache) SetCoordsDir(dir string) {
+	c.coordsDir = dir
+}
+
+// ExternalCoords returns whether the coords are from another cache, see
+// SetCoordsDir. The coords do not need to be written in this case.
+func (c *OSMCache) ExternalCoords() bool {
+	return c.coordsDir != ""
+}
+
 func (c *OSMCache) Open() error {
 	err := os.MkdirAll(c.dir, 0755)
 	if err != nil {
 		return err
 	}
-	c.Coords, err = newDeltaCoordsCache(filepath.Join(c.dir, "coords"))
+	if c.coordsDir != "" {
+		c.Coords, err = newReadOnlyDeltaCoordsCache(c.coordsDir)
+	} else {
+		c.Coords, err = newDeltaCoordsCache(filepath.Join(c.dir, "coords"))
+	}
 	if err != nil {
 		return err
 	}
@@ -154,11 +173,14 @@ type cache struct {
 	cache   *levigo.Cache
 	wo      *levigo.WriteOptions
 	ro      *levigo.ReadOptions
+
+	// readOnly opens an existing cache without creating it
+	readOnly bool
 }
 
 func (c *cache) open(path string) error {
 	opts := levigo.NewOptions()
-	opts.SetCreateIfMissing(true)
+	opts.SetCreateIfMissing(!c.readOnly)
 	if c.options.CacheSizeM > 0 {
 		c.cache = levigo.NewLRUCache(c.options.CacheSizeM * 1024 * 1024)
 		opts.SetCache(c.cache)
@@ -0,0 +1,94 @@
+/*
+Package check provides the check sub command to validate the configuration before an import.
+*/
+package check
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/omniscale/imposm3/config"
+	"github.com/omniscale/imposm3/database"
+	_ "github.com/omniscale/imposm3/database/postgis"
+	"github.com/omniscale/imposm3/geom/limit"
+	"github.com/omniscale/imposm3/log"
+	"github.com/omniscale/imposm3/mapping"
+	"github.com/pkg/errors"
+)
+
+// Check verifies the mapping, the -limitto geometries, access to cache and
+// diff directories, and the database connection and permissions. It reports
+// all failed checks and exits with an error if any check failed.
+func Check(baseOpts config.Base) {
+	failed := 0
+	report := func(name string, errs ...error) {
+		ok := true
+		for _, err := range errs {
+			if err != nil {
+				log.Printf("[error] %s: %s", name, err)
+				ok = false
+			}
+		}
+		if ok {
+			log.Printf("[info] %s: ok", name)
+		} else {
+			failed++
+		}
+	}
+
+	tagmapping, err := mapping.FromFile(baseOpts.MappingFile)
+	report("mapping", err)
+
+	if baseOpts.LimitTo != "" {
+		_, err := limit.NewFromGeoJSON(
+			baseOpts.LimitTo,
+			baseOpts.LimitToCacheBuffer,
+			baseOpts.Srid,
+		)
+		report("limitto", err)
+	}
+
+	report("cachedir", checkWritableDir(baseOpts.CacheDir))
+	if baseOpts.DiffDir != baseOpts.CacheDir {
+		report("diffdir", checkWritableDir(baseOpts.DiffDir))
+	}
+
+	if tagmapping != nil {
+		conf := database.Config{
+			ConnectionParams: baseOpts.Connection,
+			Srid:             baseOpts.Srid,
+			ImportSchema:     baseOpts.Schemas.Import,
+			ProductionSchema: baseOpts.Schemas.Production,
+			BackupSchema:     baseOpts.Schemas.Backup,
+			TablePrefix:      baseOpts.TablePrefix,
+			TableSuffix:      baseOpts.TableSuffix,
+		}
+		db, err := database.Open(conf, &tagmapping.Conf)
+		report("database", err)
+		if err == nil {
+			if checker, ok := db.(database.Checker); ok {
+				report("database permissions", checker.Check()...)
+			}
+			db.Close()
+		}
+	}
+
+	if failed > 0 {
+		log.Fatalf("[fatal] %d check(s) failed", failed)
+	}
+	log.Println("[info] all checks passed")
+}
+
+// checkWritableDir checks that dir exists (or can be created) and that files
+// can be created inside.
+func checkWritableDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrapf(err, "creating %s", dir)
+	}
+	f, err := ioutil.TempFile(dir, ".imposm-check-")
+	if err != nil {
+		return errors.Wrapf(err, "writing to %s", dir)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
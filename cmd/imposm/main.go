@@ -8,7 +8,10 @@ import (
 
 	"github.com/omniscale/imposm3"
 	"github.com/omniscale/imposm3/cache/query"
+	"github.com/omniscale/imposm3/check"
 	"github.com/omniscale/imposm3/config"
+	"github.com/omniscale/imposm3/drop"
+	"github.com/omniscale/imposm3/extract"
 	"github.com/omniscale/imposm3/import_"
 	"github.com/omniscale/imposm3/log"
 	"github.com/omniscale/imposm3/stats"
@@ -21,6 +24,9 @@ func PrintCmds() {
 	fmt.Println("\timport")
 	fmt.Println("\tdiff")
 	fmt.Println("\trun")
+	fmt.Println("\tcheck")
+	fmt.Println("\tdrop")
+	fmt.Println("\textract")
 	fmt.Println("\tquery-cache")
 	fmt.Println("\tversion")
 }
@@ -60,6 +66,15 @@ func Main(usage func()) {
 			stats.StartHTTPPProf(opts.HTTPProfile)
 		}
 		update.Run(opts)
+	case "check":
+		opts := config.ParseCheck(os.Args[2:])
+		check.Check(opts)
+	case "drop":
+		opts := config.ParseDrop(os.Args[2:])
+		drop.Drop(opts)
+	case "extract":
+		opts := config.ParseExtract(os.Args[2:])
+		extract.Extract(opts)
 	case "query-cache":
 		query.Query(os.Args[2:])
 	case "version":
@@ -6,25 +6,102 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/omniscale/imposm3/log"
 )
 
 type Config struct {
-	CacheDir            string          `json:"cachedir"`
-	DiffDir             string          `json:"diffdir"`
-	Connection          string          `json:"connection"`
-	MappingFile         string          `json:"mapping"`
-	LimitTo             string          `json:"limitto"`
-	LimitToCacheBuffer  float64         `json:"limitto_cache_buffer"`
-	Srid                int             `json:"srid"`
-	Schemas             Schemas         `json:"schemas"`
-	ExpireTilesDir      string          `json:"expiretiles_dir"`
-	ExpireTilesZoom     int             `json:"expiretiles_zoom"`
-	ReplicationURL      string          `json:"replication_url"`
-	ReplicationInterval MinutesInterval `json:"replication_interval"`
-	DiffStateBefore     MinutesInterval `json:"diff_state_before"`
+	CacheDir            string            `json:"cachedir"`
+	DiffDir             string            `json:"diffdir"`
+	Connection          string            `json:"connection"`
+	MappingFile         string            `json:"mapping"`
+	LimitTo             string            `json:"limitto"`
+	LimitToCacheBuffer  float64           `json:"limitto_cache_buffer"`
+	Srid                int               `json:"srid"`
+	Schemas             Schemas           `json:"schemas"`
+	ExpireTilesDir      string            `json:"expiretiles_dir"`
+	ExpireTilesZoom     int               `json:"expiretiles_zoom"`
+	ReplicationURL      string            `json:"replication_url"`
+	ReplicationInterval MinutesInterval   `json:"replication_interval"`
+	DiffStateBefore     MinutesInterval   `json:"diff_state_before"`
+	LogFormat           string            `json:"log_format"`
+	LogLabels           map[string]string `json:"log_labels"`
+	StatsTable          string            `json:"stats_table"`
+	StateTable          string            `json:"state_table"`
+	StatusFile          string            `json:"status_file"`
+	Alerts              Alerts            `json:"alerts"`
+	BulkBufferSize      int               `json:"bulk_buffer_size"`
+	BulkMaxRows         int               `json:"bulk_max_rows"`
+	MaxRelationMembers  int               `json:"relation_max_members"`
+	RelationMemberDepth int               `json:"relation_member_depth"`
+	RelationHugeMode    string            `json:"relation_huge_mode"`
+	RelationTolerance   float64           `json:"relation_simplify_tolerance"`
+	Workers             Workers           `json:"workers"`
+	AdminHTTP           string            `json:"admin_http"`
+	AdminToken          string            `json:"admin_token"`
+	Targets             []Target          `json:"targets"`
+	TablePrefix         string            `json:"table_prefix"`
+	TableSuffix         string            `json:"table_suffix"`
+	TableVars           map[string]string `json:"table_vars"`
+	DeployHook          DeployHook        `json:"deploy_hook"`
+	ChangeFeedHTTP      string            `json:"change_feed_http"`
+	QuarantineDir       string            `json:"quarantine_dir"`
+	MaxErrorRate        float64           `json:"max_error_rate"`
+	UpdateTables        []string          `json:"update_tables"`
+	CoordsCache         string            `json:"coords_cache"`
+	LeaderLock          string            `json:"leader_lock"`
+	HealthHTTP          string            `json:"health_http"`
+	// Profiles are partial configurations that override the options
+	// above, selected with -profile.
+	Profiles map[string]json.RawMessage `json:"profiles"`
+}
+
+// DeployHook is called after -deployproduction and -revertdeploy, e.g. to
+// purge tile caches. The rotated tables are sent as JSON to URL, or passed
+// in the environment to Command.
+type DeployHook struct {
+	URL     string   `json:"url"`
+	Command []string `json:"command"`
+}
+
+// Target is an additional mapping that is imported from the same cache
+// into another database or schema. Empty schemas default to the schemas
+// of the main mapping.
+type Target struct {
+	Mapping    string  `json:"mapping"`
+	Connection string  `json:"connection"`
+	Schemas    Schemas `json:"schemas"`
+}
+
+// Workers configures the number of goroutines of each import step. The
+// number of CPUs is used for each zero value.
+type Workers struct {
+	Read     int `json:"read"`
+	Write    int `json:"write"`
+	Database int `json:"database"`
+}
+
+// Alerts configures webhooks that are called on failures.
+type Alerts struct {
+	Hooks []AlertHook `json:"hooks"`
+	// DiffErrors is the number of consecutive failed diff imports in run
+	// mode before an alert is sent.
+	DiffErrors int `json:"diff_errors"`
+	// ReplicationLag is the maximum age of the last imported diff in run
+	// mode before an alert is sent.
+	ReplicationLag MinutesInterval `json:"replication_lag"`
+}
+
+type AlertHook struct {
+	URL string `json:"url"`
+	// Template is a text/template for the request body. The alert is sent
+	// as JSON if empty.
+	Template string `json:"template"`
+	// Events limits the hook to these events. All events are sent if empty.
+	Events []string `json:"events"`
 }
 
 type Schemas struct {
@@ -57,6 +134,56 @@ type Base struct {
 	ReplicationInterval time.Duration
 	DiffStateBefore     time.Duration
 	ForceDiffImport     bool
+	LogFormat           string
+	StatsTable          string
+	StateTable          string
+	StatusFile          string
+	Alerts              Alerts
+	BulkBufferSize      int
+	BulkMaxRows         int
+	MaxRelationMembers  int
+	RelationMemberDepth int
+	RelationHugeMode    string
+	RelationTolerance   float64
+	Workers             Workers
+	AdminHTTP           string
+	AdminToken          string
+	Targets             []Target
+	TablePrefix         string
+	TableSuffix         string
+	DeployHook          DeployHook
+	ChangeFeedHTTP      string
+	QuarantineDir       string
+	MaxErrorRate        float64
+	// UpdateTables limits diff imports to these tables. All tables are
+	// updated if empty.
+	UpdateTables tableList
+	// Profile selects one of the profiles of the configuration.
+	Profile string
+	// CoordsCache is the coords directory of an existing cache that is
+	// used instead of reading the coords again.
+	CoordsCache string
+	// LeaderLock is the name of the lock that is acquired before diffs are
+	// imported in run mode.
+	LeaderLock string
+	HealthHTTP string
+}
+
+// tableList is a comma separated list of tables.
+type tableList []string
+
+func (l *tableList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *tableList) Set(value string) error {
+	*l = nil
+	for _, t := range strings.Split(value, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			*l = append(*l, t)
+		}
+	}
+	return nil
 }
 
 func (o *Base) updateFromConfig() error {
@@ -78,6 +205,22 @@ func (o *Base) updateFromConfig() error {
 		}
 	}
 
+	if o.Profile == "" {
+		o.Profile = os.Getenv("IMPOSM_PROFILE")
+	}
+	if o.Profile != "" {
+		profile, ok := conf.Profiles[o.Profile]
+		if !ok {
+			return fmt.Errorf("profile %q not found in -config", o.Profile)
+		}
+		// options of the profile replace the options of the configuration,
+		// nested objects are merged
+		if err := json.Unmarshal(profile, conf); err != nil {
+			return fmt.Errorf("parsing profile %q: %s", o.Profile, err)
+		}
+		log.Printf("[info] Using profile %s", o.Profile)
+	}
+
 	if conf.Schemas.Import != "" && o.Schemas.Import == defaultSchemaImport {
 		o.Schemas.Import = conf.Schemas.Import
 	}
@@ -144,9 +287,125 @@ func (o *Base) updateFromConfig() error {
 	if conf.DiffStateBefore.Duration != 0 && o.DiffStateBefore == 0 {
 		o.DiffStateBefore = conf.DiffStateBefore.Duration
 	}
+
+	if o.StatsTable == "" {
+		o.StatsTable = conf.StatsTable
+	}
+	if o.StateTable == "" {
+		o.StateTable = conf.StateTable
+	}
+	if o.StatusFile == "" {
+		o.StatusFile = conf.StatusFile
+	}
+	o.Alerts = conf.Alerts
+
+	if o.BulkBufferSize == 0 {
+		o.BulkBufferSize = conf.BulkBufferSize
+	}
+	if o.BulkMaxRows == 0 {
+		o.BulkMaxRows = conf.BulkMaxRows
+	}
+	if o.MaxRelationMembers == 0 {
+		o.MaxRelationMembers = conf.MaxRelationMembers
+	}
+	if o.RelationMemberDepth == 0 {
+		o.RelationMemberDepth = conf.RelationMemberDepth
+	}
+	if o.RelationHugeMode == "" {
+		o.RelationHugeMode = conf.RelationHugeMode
+	}
+	if o.RelationTolerance == 0 {
+		o.RelationTolerance = conf.RelationTolerance
+	}
+	if o.Workers.Read == 0 {
+		o.Workers.Read = conf.Workers.Read
+	}
+	if o.Workers.Write == 0 {
+		o.Workers.Write = conf.Workers.Write
+	}
+	if o.Workers.Database == 0 {
+		o.Workers.Database = conf.Workers.Database
+	}
+
+	if o.AdminHTTP == "" {
+		o.AdminHTTP = conf.AdminHTTP
+	}
+	if o.AdminToken == "" {
+		o.AdminToken = os.Getenv("IMPOSM_ADMIN_TOKEN")
+	}
+	if o.AdminToken == "" {
+		o.AdminToken = conf.AdminToken
+	}
+	o.Targets = conf.Targets
+	o.DeployHook = conf.DeployHook
+	if o.ChangeFeedHTTP == "" {
+		o.ChangeFeedHTTP = conf.ChangeFeedHTTP
+	}
+	if o.QuarantineDir == "" {
+		o.QuarantineDir = conf.QuarantineDir
+	}
+	if o.MaxErrorRate == 0 {
+		o.MaxErrorRate = conf.MaxErrorRate
+	}
+	if len(o.UpdateTables) == 0 {
+		o.UpdateTables = conf.UpdateTables
+	}
+	if o.LeaderLock == "" {
+		o.LeaderLock = conf.LeaderLock
+	}
+	if o.CoordsCache == "" {
+		o.CoordsCache = conf.CoordsCache
+	}
+	if o.HealthHTTP == "" {
+		o.HealthHTTP = conf.HealthHTTP
+	}
+
+	if o.TablePrefix == "" {
+		o.TablePrefix = conf.TablePrefix
+	}
+	if o.TableSuffix == "" {
+		o.TableSuffix = conf.TableSuffix
+	}
+	var err error
+	if o.TablePrefix, err = expandTableVars(o.TablePrefix, conf.TableVars); err != nil {
+		return err
+	}
+	if o.TableSuffix, err = expandTableVars(o.TableSuffix, conf.TableVars); err != nil {
+		return err
+	}
+
+	if o.LogFormat == "" {
+		o.LogFormat = conf.LogFormat
+	}
+	if o.LogFormat == string(log.FormatJSON) {
+		log.SetFormat(log.FormatJSON, conf.LogLabels)
+	}
 	return nil
 }
 
+var tableVarRe = regexp.MustCompile(`{([^{}]*)}`)
+
+// expandTableVars replaces all {name} placeholders in tmpl with the value
+// from vars, or from the environment variable name if it is not in vars.
+func expandTableVars(tmpl string, vars map[string]string) (string, error) {
+	var missing []string
+	result := tableVarRe.ReplaceAllStringFunc(tmpl, func(m string) string {
+		name := m[1 : len(m)-1]
+		if v, ok := vars[name]; ok {
+			return v
+		}
+		if v, ok := os.LookupEnv(name); ok {
+			return v
+		}
+		missing = append(missing, name)
+		return m
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing table_vars or environment for %s in %q", strings.Join(missing, ", "), tmpl)
+	}
+	return result, nil
+}
+
 func (o *Base) check() []error {
 	errs := []error{}
 	if o.Srid != 3857 && o.Srid != 4326 {
@@ -155,6 +414,23 @@ func (o *Base) check() []error {
 	if o.MappingFile == "" {
 		errs = append(errs, errors.New("missing mapping"))
 	}
+	if o.LogFormat != "" && o.LogFormat != string(log.FormatText) && o.LogFormat != string(log.FormatJSON) {
+		errs = append(errs, errors.New("only -log-format=text or -log-format=json are supported"))
+	}
+	switch o.RelationHugeMode {
+	case "", "skip", "build", "simplify":
+	default:
+		errs = append(errs, errors.New("only -relation-huge-mode=skip, build or simplify are supported"))
+	}
+	if o.RelationTolerance < 0 {
+		errs = append(errs, errors.New("-relation-simplify-tolerance can not be negative"))
+	}
+	if o.MaxErrorRate < 0 || o.MaxErrorRate > 1 {
+		errs = append(errs, errors.New("-max-error-rate needs to be between 0 and 1"))
+	}
+	if o.AdminHTTP != "" && o.AdminToken == "" {
+		errs = append(errs, errors.New("-admin-http requires admin_token or IMPOSM_ADMIN_TOKEN"))
+	}
 	return errs
 }
 
@@ -166,9 +442,11 @@ type Import struct {
 	Write            bool
 	Optimize         bool
 	Diff             bool
+	Prefilter        bool
 	DeployProduction bool
 	RevertDeploy     bool
 	RemoveBackup     bool
+	Summary          string
 }
 
 func addBaseFlags(opts *Base, flags *flag.FlagSet) {
@@ -180,11 +458,17 @@ func addBaseFlags(opts *Base, flags *flag.FlagSet) {
 	flags.StringVar(&opts.LimitTo, "limitto", "", "limit to geometries")
 	flags.Float64Var(&opts.LimitToCacheBuffer, "limittocachebuffer", 0.0, "limit to buffer for cache")
 	flags.StringVar(&opts.ConfigFile, "config", "", "config (json)")
-	flags.StringVar(&opts.HTTPProfile, "httpprofile", "", "bind address for profile server")
+	flags.StringVar(&opts.Profile, "profile", "", "use this profile of the config (or IMPOSM_PROFILE)")
+	flags.StringVar(&opts.HTTPProfile, "httpprofile", "", "bind address for pprof and expvar server (e.g. localhost:6060)")
 	flags.BoolVar(&opts.Quiet, "quiet", false, "quiet log output")
+	flags.StringVar(&opts.LogFormat, "log-format", "", "log output format (text or json)")
 	flags.StringVar(&opts.Schemas.Import, "dbschema-import", defaultSchemaImport, "db schema for imports")
 	flags.StringVar(&opts.Schemas.Production, "dbschema-production", defaultSchemaProduction, "db schema for production")
 	flags.StringVar(&opts.Schemas.Backup, "dbschema-backup", defaultSchemaBackup, "db schema for backups")
+	flags.StringVar(&opts.TablePrefix, "table-prefix", "", "prefix for all table names, replaces prefix of the connection (e.g. {region}_)")
+	flags.StringVar(&opts.TableSuffix, "table-suffix", "", "suffix for all table names (e.g. _{region})")
+	flags.StringVar(&opts.StatsTable, "stats-table", "", "write per-table statistics into this table of the production schema")
+	flags.StringVar(&opts.StateTable, "state-table", "", "store the last diff state in this table of the production schema")
 }
 
 func isFlagActual(flags *flag.FlagSet, name string) bool {
@@ -208,9 +492,23 @@ func ParseImport(args []string) Import {
 	flags.BoolVar(&opts.Write, "write", false, "write")
 	flags.BoolVar(&opts.Optimize, "optimize", false, "optimize")
 	flags.BoolVar(&opts.Diff, "diff", false, "enable diff support")
+	flags.StringVar(&opts.Base.CoordsCache, "coords-cache", "", "use the node coordinates from this coords directory of an existing cache (not with -diff)")
+	flags.BoolVar(&opts.Prefilter, "prefilter", false, "only cache elements that can match the mapping (reads the input three times, not with -diff)")
 	flags.BoolVar(&opts.DeployProduction, "deployproduction", false, "deploy production")
 	flags.BoolVar(&opts.RevertDeploy, "revertdeploy", false, "revert deploy to production")
 	flags.BoolVar(&opts.RemoveBackup, "removebackup", false, "remove backups from deploy")
+	flags.StringVar(&opts.Summary, "summary", "", "write summary of the import as JSON into this file")
+	flags.IntVar(&opts.Base.BulkBufferSize, "bulk-buffer-size", 0, "number of rows buffered for each table (default 64)")
+	flags.IntVar(&opts.Base.BulkMaxRows, "bulk-max-rows", 0, "max number of rows buffered for all tables (default unlimited)")
+	flags.IntVar(&opts.Base.MaxRelationMembers, "relation-max-members", 0, "relations with more members are huge relations, see -relation-huge-mode (default unlimited)")
+	flags.IntVar(&opts.Base.RelationMemberDepth, "relation-member-depth", 0, "resolve geometries of member relations up to this depth for relation_member tables")
+	flags.StringVar(&opts.Base.RelationHugeMode, "relation-huge-mode", "", "skip, build or simplify the multipolygons of huge relations, with bounded memory (default skip)")
+	flags.Float64Var(&opts.Base.RelationTolerance, "relation-simplify-tolerance", 0, "tolerance in -srid units for -relation-huge-mode=simplify (default 10m)")
+	flags.StringVar(&opts.Base.QuarantineDir, "quarantine-dir", "", "write elements that could not be inserted into this directory")
+	flags.Float64Var(&opts.Base.MaxErrorRate, "max-error-rate", 0, "abort if more elements of a table could not be inserted (e.g. 0.01, default unlimited)")
+	flags.IntVar(&opts.Base.Workers.Read, "read-workers", 0, "number of CPUs for reading (default all)")
+	flags.IntVar(&opts.Base.Workers.Write, "write-workers", 0, "number of goroutines for building geometries (default number of CPUs)")
+	flags.IntVar(&opts.Base.Workers.Database, "db-workers", 0, "number of connections for indexing and generalizing (default GOMAXPROCS)")
 	flags.DurationVar(&opts.Base.DiffStateBefore, "diff-state-before", 0, "set initial diff sequence before")
 	flags.DurationVar(&opts.Base.ReplicationInterval, "replication-interval", time.Minute, "replication interval as duration (1m, 1h, 24h)")
 
@@ -233,6 +531,12 @@ func ParseImport(args []string) Import {
 		log.Fatal(err)
 	}
 	errs := opts.Base.check()
+	if opts.Prefilter && opts.Diff {
+		errs = append(errs, errors.New("-prefilter can not be combined with -diff, diff imports require all elements in the cache"))
+	}
+	if opts.Base.CoordsCache != "" && opts.Diff {
+		errs = append(errs, errors.New("-coords-cache can not be combined with -diff, diff imports need to update the coords"))
+	}
 	if len(errs) != 0 {
 		reportErrors(errs)
 		flags.Usage()
@@ -248,6 +552,11 @@ func ParseDiffImport(args []string) (Base, []string) {
 	flags.StringVar(&opts.ExpireTilesDir, "expiretiles-dir", "", "write expire tiles into dir")
 	flags.IntVar(&opts.ExpireTilesZoom, "expiretiles-zoom", 14, "write expire tiles in this zoom level")
 	flags.BoolVar(&opts.ForceDiffImport, "force", false, "force import of diff if sequence was already imported")
+	flags.IntVar(&opts.MaxRelationMembers, "relation-max-members", 0, "relations with more members are huge relations, see -relation-huge-mode (default unlimited)")
+	flags.IntVar(&opts.RelationMemberDepth, "relation-member-depth", 0, "resolve geometries of member relations up to this depth for relation_member tables")
+	flags.StringVar(&opts.RelationHugeMode, "relation-huge-mode", "", "skip, build or simplify the multipolygons of huge relations, with bounded memory (default skip)")
+	flags.Float64Var(&opts.RelationTolerance, "relation-simplify-tolerance", 0, "tolerance in -srid units for -relation-huge-mode=simplify (default 10m)")
+	flags.Var(&opts.UpdateTables, "update-tables", "only update these tables (comma separated, default all)")
 
 	flags.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s %s [args] [.osc.gz, ...]\n\n", os.Args[0], os.Args[1])
@@ -291,6 +600,16 @@ func ParseRunImport(args []string) Base {
 	flags.StringVar(&opts.ExpireTilesDir, "expiretiles-dir", "", "write expire tiles into dir")
 	flags.IntVar(&opts.ExpireTilesZoom, "expiretiles-zoom", 14, "write expire tiles in this zoom level")
 	flags.DurationVar(&opts.ReplicationInterval, "replication-interval", time.Minute, "replication interval as duration (1m, 1h, 24h)")
+	flags.StringVar(&opts.StatusFile, "status-file", "", "periodically write status as JSON into this file")
+	flags.StringVar(&opts.AdminHTTP, "admin-http", "", "bind address for admin API (e.g. localhost:8080)")
+	flags.StringVar(&opts.ChangeFeedHTTP, "change-feed-http", "", "bind address for server-sent events of all changes (e.g. localhost:8081)")
+	flags.StringVar(&opts.HealthHTTP, "health-http", "", "bind address for /healthz and /readyz endpoints (e.g. :8082)")
+	flags.IntVar(&opts.MaxRelationMembers, "relation-max-members", 0, "relations with more members are huge relations, see -relation-huge-mode (default unlimited)")
+	flags.IntVar(&opts.RelationMemberDepth, "relation-member-depth", 0, "resolve geometries of member relations up to this depth for relation_member tables")
+	flags.StringVar(&opts.RelationHugeMode, "relation-huge-mode", "", "skip, build or simplify the multipolygons of huge relations, with bounded memory (default skip)")
+	flags.Float64Var(&opts.RelationTolerance, "relation-simplify-tolerance", 0, "tolerance in -srid units for -relation-huge-mode=simplify (default 10m)")
+	flags.Var(&opts.UpdateTables, "update-tables", "only update these tables (comma separated, default all)")
+	flags.StringVar(&opts.LeaderLock, "leader-lock", "", "only import diffs while holding this database lock, for multiple replicas")
 
 	flags.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s %s [args] [.osc.gz, ...]\n\n", os.Args[0], os.Args[1])
@@ -326,6 +645,129 @@ func ParseRunImport(args []string) Base {
 	return opts
 }
 
+func ParseCheck(args []string) Base {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	opts := Base{}
+
+	addBaseFlags(&opts, flags)
+
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s [args]\n\n", os.Args[0], os.Args[1])
+		flags.PrintDefaults()
+		os.Exit(2)
+	}
+
+	if len(args) == 0 {
+		flags.Usage()
+	}
+
+	err := flags.Parse(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = opts.updateFromConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	errs := opts.check()
+	if opts.Connection == "" {
+		errs = append(errs, errors.New("missing connection"))
+	}
+	if len(errs) != 0 {
+		reportErrors(errs)
+		flags.Usage()
+	}
+
+	return opts
+}
+
+type Drop struct {
+	Base       Base
+	Production bool
+}
+
+func ParseDrop(args []string) Drop {
+	flags := flag.NewFlagSet("drop", flag.ExitOnError)
+	opts := Drop{}
+
+	addBaseFlags(&opts.Base, flags)
+	flags.BoolVar(&opts.Production, "production", false, "also drop tables from production schema")
+
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s [args]\n\n", os.Args[0], os.Args[1])
+		flags.PrintDefaults()
+		os.Exit(2)
+	}
+
+	if len(args) == 0 {
+		flags.Usage()
+	}
+
+	err := flags.Parse(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = opts.Base.updateFromConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	errs := opts.Base.check()
+	if opts.Base.Connection == "" {
+		errs = append(errs, errors.New("missing connection"))
+	}
+	if len(errs) != 0 {
+		reportErrors(errs)
+		flags.Usage()
+	}
+
+	return opts
+}
+
+type Extract struct {
+	Base   Base
+	Output string
+}
+
+func ParseExtract(args []string) Extract {
+	flags := flag.NewFlagSet("extract", flag.ExitOnError)
+	opts := Extract{}
+
+	addBaseFlags(&opts.Base, flags)
+	flags.StringVar(&opts.Output, "output", "", "write matching elements to this .osm.pbf file")
+
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s [args]\n\n", os.Args[0], os.Args[1])
+		flags.PrintDefaults()
+		os.Exit(2)
+	}
+
+	if len(args) == 0 {
+		flags.Usage()
+	}
+
+	err := flags.Parse(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = opts.Base.updateFromConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	errs := opts.Base.check()
+	if opts.Output == "" {
+		errs = append(errs, errors.New("missing output"))
+	}
+	if len(errs) != 0 {
+		reportErrors(errs)
+		flags.Usage()
+	}
+
+	return opts
+}
+
 func reportErrors(errs []error) {
 	fmt.Println("errors in config/options:")
 	for _, err := range errs {
@@ -0,0 +1,286 @@
+/*
+Package csv implements a database backend that writes the rows of each table
+into a separate CSV file.
+
+Use "csv:/path/to/dir" as connection to write comma separated files, or
+"tsv:/path/to/dir" for tab separated files. Each file is named after the
+table (e.g. roads.csv) and starts with a header row with the column names.
+Geometries are encoded as hex EWKB, NULL values as empty fields. Use
+"csv:/path/to/dir?geometry=wkt" to encode geometries as WKT.
+*/
+package csv
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/database"
+	"github.com/omniscale/imposm3/geom"
+	"github.com/omniscale/imposm3/geom/geos"
+	"github.com/omniscale/imposm3/log"
+	"github.com/omniscale/imposm3/mapping"
+	"github.com/omniscale/imposm3/mapping/config"
+	"github.com/pkg/errors"
+)
+
+type table struct {
+	mu       sync.Mutex
+	name     string
+	columns  []string
+	geometry []bool
+	f        *os.File
+	buf      *bufio.Writer
+	w        *csv.Writer
+	record   []string
+	// g converts geometries to WKT, nil for hex EWKB
+	g *geos.Geos
+}
+
+type CSV struct {
+	dir    string
+	ext    string
+	comma  rune
+	wkt    bool
+	tables map[string]*table
+}
+
+func New(conf database.Config, m *config.Mapping) (database.DB, error) {
+	parts := strings.SplitN(conf.ConnectionParams, ":", 2)
+	c := &CSV{ext: ".csv", comma: ','}
+	if parts[0] == "tsv" {
+		c.ext, c.comma = ".tsv", '\t'
+	}
+	if len(parts) == 2 {
+		dir := strings.TrimSpace(parts[1])
+		if i := strings.Index(dir, "?"); i >= 0 {
+			if err := c.parseOptions(dir[i+1:]); err != nil {
+				return nil, err
+			}
+			dir = dir[:i]
+		}
+		c.dir = dir
+	}
+	if c.dir == "" {
+		return nil, errors.Errorf("missing output directory in connection, e.g. %s:/tmp/osm", parts[0])
+	}
+
+	c.tables = make(map[string]*table)
+	for name, t := range m.Tables {
+		tbl := &table{name: name}
+		for _, col := range t.Columns {
+			colType, err := mapping.MakeColumnType(col)
+			if err != nil {
+				return nil, errors.Wrapf(err, "creating column %q of table %q", col.Name, name)
+			}
+			tbl.columns = append(tbl.columns, col.Name)
+			tbl.geometry = append(tbl.geometry, colType.GoType == "geometry" || colType.GoType == "validated_geometry")
+		}
+		c.tables[name] = tbl
+	}
+	if len(m.GeneralizedTables) > 0 {
+		log.Println("[warn] Generalized tables are not written to " + parts[0])
+	}
+	return c, nil
+}
+
+// parseOptions parses the query of the connection, e.g. geometry=wkt.
+func (c *CSV) parseOptions(query string) error {
+	opts, err := url.ParseQuery(query)
+	if err != nil {
+		return errors.Wrap(err, "parsing connection options")
+	}
+	for k, v := range opts {
+		switch k {
+		case "geometry":
+			switch v[0] {
+			case "wkt":
+				c.wkt = true
+			case "hex":
+				c.wkt = false
+			default:
+				return errors.Errorf("unknown geometry encoding %q, use hex or wkt", v[0])
+			}
+		default:
+			return errors.Errorf("unknown connection option %q", k)
+		}
+	}
+	return nil
+}
+
+// Init creates the output directory and a file with the header row for each
+// table. Existing files are overwritten.
+func (c *CSV) Init() error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return errors.Wrap(err, "creating output directory")
+	}
+	for _, name := range c.tableNames() {
+		tbl := c.tables[name]
+		f, err := os.Create(filepath.Join(c.dir, name+c.ext))
+		if err != nil {
+			return errors.Wrapf(err, "creating file for table %q", name)
+		}
+		tbl.f = f
+		tbl.buf = bufio.NewWriterSize(f, 256*1024)
+		tbl.w = csv.NewWriter(tbl.buf)
+		tbl.w.Comma = c.comma
+		if c.wkt {
+			tbl.g = geos.NewGeos()
+		}
+		if err := tbl.w.Write(tbl.columns); err != nil {
+			return errors.Wrapf(err, "writing header of table %q", name)
+		}
+	}
+	return nil
+}
+
+func (c *CSV) Begin() error { return nil }
+
+func (c *CSV) End() error {
+	for _, name := range c.tableNames() {
+		if err := c.tables[name].flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *CSV) Abort() error { return c.Close() }
+
+func (c *CSV) Close() error {
+	var firstErr error
+	for _, name := range c.tableNames() {
+		tbl := c.tables[name]
+		if tbl.f == nil {
+			continue
+		}
+		if err := tbl.flush(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if err := tbl.f.Close(); err != nil && firstErr == nil {
+			firstErr = errors.Wrapf(err, "closing file of table %q", name)
+		}
+		if tbl.g != nil {
+			tbl.g.Finish()
+		}
+		tbl.f, tbl.w, tbl.g = nil, nil, nil
+	}
+	return firstErr
+}
+
+// Generalize, EnableGeneralizeUpdates and GeneralizeUpdates are no-ops, as
+// generalized tables are not supported.
+func (c *CSV) Generalize() error        { return nil }
+func (c *CSV) EnableGeneralizeUpdates() {}
+func (c *CSV) GeneralizeUpdates() error { return nil }
+
+// Finish is a no-op, as there are no indices.
+func (c *CSV) Finish() error { return nil }
+
+func (c *CSV) InsertPoint(elem osm.Element, geom geom.Geometry, matches []mapping.Match) error {
+	return c.insert(elem, geom, matches)
+}
+
+func (c *CSV) InsertLineString(elem osm.Element, geom geom.Geometry, matches []mapping.Match) error {
+	return c.insert(elem, geom, matches)
+}
+
+func (c *CSV) InsertPolygon(elem osm.Element, geom geom.Geometry, matches []mapping.Match) error {
+	return c.insert(elem, geom, matches)
+}
+
+func (c *CSV) insert(elem osm.Element, geom geom.Geometry, matches []mapping.Match) error {
+	return database.InsertRows(elem, geom, matches, c.insertRow)
+}
+
+func (c *CSV) InsertRelationMember(rel osm.Relation, m osm.Member, mi int, geom geom.Geometry, matches []mapping.Match) error {
+	return database.InsertMemberRows(rel, m, mi, geom, matches, c.insertRow)
+}
+
+func (c *CSV) insertRow(table string, elem *osm.Element, row []interface{}) error {
+	tbl, ok := c.tables[table]
+	if !ok {
+		return nil
+	}
+	return tbl.write(row)
+}
+
+func (c *CSV) tableNames() []string {
+	names := make([]string, 0, len(c.tables))
+	for name := range c.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (t *table) write(row []interface{}) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.w == nil {
+		return errors.Errorf("table %q not initialized, csv requires -write", t.name)
+	}
+	t.record = t.record[:0]
+	for i, v := range row {
+		if v == nil {
+			t.record = append(t.record, "")
+		} else if t.g != nil && t.geometry[i] && v != "" {
+			wkt, err := t.asWkt(v.(string))
+			if err != nil {
+				return err
+			}
+			t.record = append(t.record, wkt)
+		} else {
+			t.record = append(t.record, fmt.Sprint(v))
+		}
+	}
+	if err := t.w.Write(t.record); err != nil {
+		return errors.Wrapf(err, "writing row of table %q", t.name)
+	}
+	return nil
+}
+
+// asWkt converts a hex EWKB geometry to WKT. The SRID is not included.
+func (t *table) asWkt(ewkb string) (string, error) {
+	wkb, err := hex.DecodeString(ewkb)
+	if err != nil {
+		return "", errors.Wrapf(err, "decoding geometry of table %q", t.name)
+	}
+	g := t.g.FromWkb(wkb)
+	if g == nil {
+		return "", errors.Errorf("converting geometry of table %q to WKT", t.name)
+	}
+	defer t.g.Destroy(g)
+	return t.g.AsWkt(g), nil
+}
+
+func (t *table) flush() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.w == nil {
+		return nil
+	}
+	t.w.Flush()
+	if err := t.w.Error(); err != nil {
+		return errors.Wrapf(err, "writing table %q", t.name)
+	}
+	if err := t.buf.Flush(); err != nil {
+		return errors.Wrapf(err, "writing table %q", t.name)
+	}
+	return nil
+}
+
+func init() {
+	database.Register("csv", New)
+	database.Register("tsv", New)
+}
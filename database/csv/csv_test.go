@@ -0,0 +1,176 @@
+package csv
+
+import (
+	"encoding/csv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/database"
+	"github.com/omniscale/imposm3/geom"
+	"github.com/omniscale/imposm3/mapping"
+)
+
+const testMapping = `
+tables:
+  pois:
+    type: point
+    columns:
+    - name: osm_id
+      type: id
+    - name: name
+      key: name
+      type: string
+    - name: population
+      key: population
+      type: integer
+    - name: geometry
+      type: geometry
+    mapping:
+      place: [__any__]
+`
+
+// pointEWKB is POINT(1 2) with SRID 3857
+const pointEWKB = "0101000020110F0000000000000000F03F0000000000000040"
+
+func writePois(t *testing.T, connection string, nodes ...osm.Node) database.DB {
+	m, err := mapping.New([]byte(testMapping))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := New(database.Config{ConnectionParams: connection}, &m.Conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Init(); err != nil {
+		t.Fatal(err)
+	}
+	g := geom.Geometry{Wkb: []byte(pointEWKB)}
+	for _, node := range nodes {
+		if err := db.InsertPoint(node.Element, g, m.PointMatcher.MatchNode(&node)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.End(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func readRecords(t *testing.T, filename string, comma rune) [][]string {
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.Comma = comma
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, tt := range []struct {
+		prefix string
+		ext    string
+		comma  rune
+	}{
+		{"csv", ".csv", ','},
+		{"tsv", ".tsv", '\t'},
+	} {
+		t.Run(tt.prefix, func(t *testing.T) {
+			tmpdir, err := ioutil.TempDir("", "imposm3_csv_test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(tmpdir)
+
+			db := writePois(t, tt.prefix+":"+tmpdir,
+				osm.Node{Element: osm.Element{ID: 1, Tags: osm.Tags{"place": "city", "name": "Foo, \"Bar\"\tBaz", "population": "1000"}}},
+				osm.Node{Element: osm.Element{ID: 2, Tags: osm.Tags{"place": "village"}}},
+			)
+			if err := db.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			records := readRecords(t, filepath.Join(tmpdir, "pois"+tt.ext), tt.comma)
+			expected := [][]string{
+				{"osm_id", "name", "population", "geometry"},
+				{"1", "Foo, \"Bar\"\tBaz", "1000", pointEWKB},
+				// missing name is an empty string, missing population NULL
+				{"2", "", "", pointEWKB},
+			}
+			if !reflect.DeepEqual(records, expected) {
+				t.Errorf("unexpected records\n%q\nexpected\n%q", records, expected)
+			}
+		})
+	}
+}
+
+func TestWKT(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "imposm3_csv_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	db := writePois(t, "csv:"+tmpdir+"?geometry=wkt",
+		osm.Node{Element: osm.Element{ID: 1, Tags: osm.Tags{"place": "city"}}},
+	)
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records := readRecords(t, filepath.Join(tmpdir, "pois.csv"), ',')
+	if len(records) != 2 {
+		t.Fatalf("unexpected records %q", records)
+	}
+	if wkt := records[1][3]; !strings.HasPrefix(wkt, "POINT (1") {
+		t.Errorf("unexpected geometry %q", wkt)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	m, err := mapping.New([]byte(testMapping))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		connection string
+		dir        string
+		wkt        bool
+		errMatch   string
+	}{
+		{connection: "csv:/tmp/osm", dir: "/tmp/osm"},
+		{connection: "csv:/tmp/osm?geometry=wkt", dir: "/tmp/osm", wkt: true},
+		{connection: "tsv:/tmp/osm?geometry=hex", dir: "/tmp/osm"},
+		{connection: "csv:", errMatch: "missing output directory"},
+		{connection: "csv:?geometry=wkt", errMatch: "missing output directory"},
+		{connection: "csv:/tmp/osm?geometry=wkb", errMatch: "unknown geometry encoding"},
+		{connection: "csv:/tmp/osm?foo=bar", errMatch: "unknown connection option"},
+	} {
+		t.Run(tt.connection, func(t *testing.T) {
+			db, err := New(database.Config{ConnectionParams: tt.connection}, &m.Conf)
+			if tt.errMatch != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errMatch) {
+					t.Errorf("expected error with %q, got %v", tt.errMatch, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := db.(*CSV)
+			if c.dir != tt.dir || c.wkt != tt.wkt {
+				t.Errorf("unexpected dir %q and wkt %v", c.dir, c.wkt)
+			}
+		})
+	}
+}
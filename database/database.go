@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/go-osm/state"
 	"github.com/omniscale/imposm3/geom"
 	"github.com/omniscale/imposm3/mapping"
 	"github.com/omniscale/imposm3/mapping/config"
@@ -16,6 +17,31 @@ type Config struct {
 	ImportSchema     string
 	ProductionSchema string
 	BackupSchema     string
+	// StatsTable is the name of the table for per-table import statistics.
+	// Statistics are not written if empty.
+	StatsTable string
+	// StateTable is the name of the table for the replication state of
+	// the last imported diff. The state is not stored if empty.
+	StateTable string
+	// BulkBufferSize is the number of rows buffered for each table during
+	// bulk imports. The backend uses its default if 0.
+	BulkBufferSize int
+	// BulkMaxRows limits the number of buffered rows of all tables during
+	// bulk imports. Unlimited if 0.
+	BulkMaxRows int
+	// Workers is the number of concurrent connections for index creation,
+	// generalization and optimization. Uses GOMAXPROCS if 0.
+	Workers int
+	// TablePrefix replaces the table prefix of the ConnectionParams if not
+	// empty. TableSuffix is appended to all table names.
+	TablePrefix string
+	TableSuffix string
+	// QuarantineDir is the directory for elements that could not be
+	// inserted, with one file for each table. Not written if empty.
+	QuarantineDir string
+	// MaxErrorRate aborts the import if the ratio of failed elements of a
+	// table exceeds this value (e.g. 0.01 for 1%). Unlimited if 0.
+	MaxErrorRate float64
 }
 
 type DB interface {
@@ -46,6 +72,18 @@ type Deployer interface {
 	RemoveBackup() error
 }
 
+// DeployReporter returns the names of the tables that were moved by the last
+// Deploy or RevertDeploy.
+type DeployReporter interface {
+	DeployedTables() []string
+}
+
+// Dropper removes all tables of the mapping from the import and backup schema,
+// and from the production schema if production is true.
+type Dropper interface {
+	Drop(production bool) error
+}
+
 type Generalizer interface {
 	Generalize() error
 	EnableGeneralizeUpdates()
@@ -65,6 +103,74 @@ type Optimizer interface {
 	Optimize() error
 }
 
+// ErrorReporter is notified about elements that matched tables of the
+// mapping, but that could not be inserted (e.g. because of invalid
+// geometries).
+type ErrorReporter interface {
+	ReportError(elem osm.Element, matches []mapping.Match, err error)
+}
+
+// StatsWriter persists per-table statistics of the current import or update
+// run (e.g. "import" or "diff").
+type StatsWriter interface {
+	WriteStats(run string) error
+}
+
+// TableStats are the number of inserted and deleted rows of a table, and
+// the number of elements that could not be inserted.
+type TableStats struct {
+	Inserted int64 `json:"inserted"`
+	Deleted  int64 `json:"deleted"`
+	Errors   int64 `json:"errors"`
+}
+
+// StatsReporter returns the TableStats of the current run for all tables.
+type StatsReporter interface {
+	TableStats() map[string]TableStats
+}
+
+// Checker verifies that the database is usable for imports and updates,
+// before any data is read. Check returns all problems found.
+type Checker interface {
+	Check() []error
+}
+
+// StateStore persists the replication state of the last imported diff in
+// the database, in addition to the last.state.txt of the diff directory.
+// ReadState returns nil if no state was stored.
+type StateStore interface {
+	WriteState(*state.DiffState) error
+	ReadState() (*state.DiffState, error)
+}
+
+// TxStateStore is a StateStore that can write the state within the
+// transaction of Begin. The state is then committed by End together with
+// the imported diff.
+type TxStateStore interface {
+	StateStore
+	WriteStateTx(*state.DiffState) error
+}
+
+// Pinger verifies that the connection to the database is alive.
+type Pinger interface {
+	Ping() error
+}
+
+// Locker acquires a lock that is shared between multiple processes, e.g.
+// to ensure that only one of multiple replicas imports diffs. TryLock
+// returns false if another process holds the lock. The lock is held till
+// the database is closed. CheckLock returns an error if the lock was lost.
+type Locker interface {
+	TryLock(name string) (bool, error)
+	CheckLock() error
+}
+
+// Asserter checks the assertions of the mapping against the imported
+// tables. Assert returns all violated assertions.
+type Asserter interface {
+	Assert() []error
+}
+
 var databases map[string]func(Config, *config.Mapping) (DB, error)
 
 func init() {
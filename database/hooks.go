@@ -0,0 +1,141 @@
+package database
+
+import (
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/geom"
+	"github.com/omniscale/imposm3/mapping"
+)
+
+// RowHook is called for each row before it is inserted into table (the
+// name of the table in the mapping, without prefix). The row contains the
+// values in the order of the columns of the mapping and hooks can modify
+// these values in place. The row is not inserted if any hook returns
+// false.
+//
+// Hooks are called concurrently and need to be safe for concurrent use.
+type RowHook func(table string, elem *osm.Element, row []interface{}) bool
+
+// FinishHook is called after an import or update was written and after
+// all tables are finished (indexed and generalized).
+type FinishHook func(db DB) error
+
+var rowHooks []RowHook
+var finishHooks []FinishHook
+
+// RegisterRowHook adds a RowHook for all tables. Hooks need to be
+// registered before the import or update starts, e.g. in an init function.
+func RegisterRowHook(hook RowHook) {
+	rowHooks = append(rowHooks, hook)
+}
+
+// RegisterFinishHook adds a FinishHook. Hooks need to be registered before
+// the import or update starts, e.g. in an init function.
+func RegisterFinishHook(hook FinishHook) {
+	finishHooks = append(finishHooks, hook)
+}
+
+// RunRowHooks calls all registered RowHooks and returns whether the row
+// should be inserted. Backends call this for each row.
+func RunRowHooks(table string, elem *osm.Element, row []interface{}) bool {
+	for _, hook := range rowHooks {
+		if !hook(table, elem, row) {
+			return false
+		}
+	}
+	return true
+}
+
+// RunFinishHooks calls all registered FinishHooks and stops at the first
+// error.
+func RunFinishHooks(db DB) error {
+	for _, hook := range finishHooks {
+		if err := hook(db); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ElementFilter is called for each element that matched a table, before
+// the row is built. The element is not inserted into the table if the
+// filter returns false. Filters can modify the tags of elem, e.g. to change
+// the values of tag columns. Each filter receives a copy of the tags, so
+// changes are only visible for this table.
+//
+// Filters are called concurrently and need to be safe for concurrent use.
+type ElementFilter func(elem *osm.Element) bool
+
+var elementFilters = map[string][]ElementFilter{}
+
+// RegisterElementFilter adds an ElementFilter for table (the name of the
+// table in the mapping, without prefix). Filters need to be registered
+// before the import or update starts, e.g. in an init function.
+func RegisterElementFilter(table string, filter ElementFilter) {
+	elementFilters[table] = append(elementFilters[table], filter)
+}
+
+// FilterElement calls all ElementFilters of table and returns the
+// (modified) element and whether it should be inserted. Backends call this
+// for each element and table, before building the row.
+func FilterElement(table string, elem osm.Element) (osm.Element, bool) {
+	filters, ok := elementFilters[table]
+	if !ok {
+		return elem, true
+	}
+	tags := make(osm.Tags, len(elem.Tags))
+	for k, v := range elem.Tags {
+		tags[k] = v
+	}
+	elem.Tags = tags
+	for _, filter := range filters {
+		if !filter(&elem) {
+			return elem, false
+		}
+	}
+	return elem, true
+}
+
+// InsertFunc inserts a single row of elem into table.
+type InsertFunc func(table string, elem *osm.Element, row []interface{}) error
+
+// InsertRows builds the rows of elem for all matches and passes them to
+// insert. It calls the ElementFilters before and the RowHooks after building
+// each row, and skips rows that are rejected. Backends use this in
+// InsertPoint, InsertLineString and InsertPolygon.
+func InsertRows(elem osm.Element, g geom.Geometry, matches []mapping.Match, insert InsertFunc) error {
+	for _, match := range matches {
+		elem, ok := FilterElement(match.Table.Name, elem)
+		if !ok {
+			continue
+		}
+		row := match.Row(&elem, &g)
+		if !RunRowHooks(match.Table.Name, &elem, row) {
+			continue
+		}
+		if err := insert(match.Table.Name, &elem, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InsertMemberRows is like InsertRows, but for the member mi of the
+// relation rel. Backends use this in InsertRelationMember.
+func InsertMemberRows(rel osm.Relation, m osm.Member, mi int, g geom.Geometry, matches []mapping.Match, insert InsertFunc) error {
+	for _, match := range matches {
+		rel := rel
+		var ok bool
+		rel.Element, ok = FilterElement(match.Table.Name, rel.Element)
+		if !ok {
+			continue
+		}
+		row := match.MemberRow(&rel, &m, mi, &g)
+		if !RunRowHooks(match.Table.Name, &rel.Element, row) {
+			continue
+		}
+		if err := insert(match.Table.Name, &rel.Element, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/geom"
+	"github.com/omniscale/imposm3/mapping"
+)
+
+// withoutHooks removes all registered hooks and filters till the returned
+// function is called.
+func withoutHooks() func() {
+	savedRowHooks, savedFinishHooks, savedFilters := rowHooks, finishHooks, elementFilters
+	rowHooks, finishHooks, elementFilters = nil, nil, map[string][]ElementFilter{}
+	return func() {
+		rowHooks, finishHooks, elementFilters = savedRowHooks, savedFinishHooks, savedFilters
+	}
+}
+
+func TestRowHooks(t *testing.T) {
+	defer withoutHooks()()
+
+	var calls []string
+	RegisterRowHook(func(table string, elem *osm.Element, row []interface{}) bool {
+		calls = append(calls, "first")
+		row[1] = "modified"
+		return table != "dropped"
+	})
+	RegisterRowHook(func(table string, elem *osm.Element, row []interface{}) bool {
+		calls = append(calls, "second:"+row[1].(string))
+		return true
+	})
+
+	row := []interface{}{int64(1), "name"}
+	if !RunRowHooks("roads", &osm.Element{ID: 1}, row) {
+		t.Error("row dropped")
+	}
+	// hooks are called in order of registration and see the changes of
+	// previous hooks
+	if !reflect.DeepEqual(calls, []string{"first", "second:modified"}) {
+		t.Errorf("unexpected calls %v", calls)
+	}
+
+	calls = nil
+	if RunRowHooks("dropped", &osm.Element{ID: 1}, []interface{}{int64(1), "name"}) {
+		t.Error("row not dropped")
+	}
+	// later hooks are not called for dropped rows
+	if !reflect.DeepEqual(calls, []string{"first"}) {
+		t.Errorf("unexpected calls %v", calls)
+	}
+}
+
+func TestFinishHooks(t *testing.T) {
+	defer withoutHooks()()
+
+	if err := RunFinishHooks(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var calls []int
+	hookErr := errors.New("failed")
+	RegisterFinishHook(func(db DB) error { calls = append(calls, 1); return nil })
+	RegisterFinishHook(func(db DB) error { calls = append(calls, 2); return hookErr })
+	RegisterFinishHook(func(db DB) error { calls = append(calls, 3); return nil })
+
+	if err := RunFinishHooks(nil); err != hookErr {
+		t.Errorf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(calls, []int{1, 2}) {
+		t.Errorf("unexpected calls %v", calls)
+	}
+}
+
+const testMapping = `
+tables:
+  pois:
+    type: point
+    columns:
+    - name: osm_id
+      type: id
+    - name: name
+      key: name
+      type: string
+    mapping:
+      amenity: [__any__]
+  places:
+    type: point
+    columns:
+    - name: osm_id
+      type: id
+    - name: name
+      key: name
+      type: string
+    mapping:
+      amenity: [__any__]
+`
+
+type insertedRow struct {
+	table string
+	id    int64
+	row   []interface{}
+}
+
+func insertNode(t *testing.T, node osm.Node) []insertedRow {
+	m, err := mapping.New([]byte(testMapping))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []insertedRow
+	err = InsertRows(node.Element, geom.Geometry{}, m.PointMatcher.MatchNode(&node),
+		func(table string, elem *osm.Element, row []interface{}) error {
+			rows = append(rows, insertedRow{table, elem.ID, row})
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func TestInsertRowsWithRowHooks(t *testing.T) {
+	defer withoutHooks()()
+
+	RegisterRowHook(func(table string, elem *osm.Element, row []interface{}) bool {
+		if table == "places" {
+			return false
+		}
+		row[1] = "Hooked " + row[1].(string)
+		return true
+	})
+
+	rows := insertNode(t, osm.Node{Element: osm.Element{ID: 1, Tags: osm.Tags{"amenity": "cafe", "name": "Foo"}}})
+	expected := []insertedRow{{"pois", 1, []interface{}{int64(1), "Hooked Foo"}}}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("unexpected rows %v", rows)
+	}
+}
+
+func TestElementFilters(t *testing.T) {
+	defer withoutHooks()()
+
+	var calls []string
+	RegisterElementFilter("pois", func(elem *osm.Element) bool {
+		calls = append(calls, "first")
+		elem.Tags["name"] = "Filtered " + elem.Tags["name"]
+		return elem.Tags["access"] != "private"
+	})
+	RegisterElementFilter("pois", func(elem *osm.Element) bool {
+		calls = append(calls, "second:"+elem.Tags["name"])
+		return true
+	})
+
+	node := osm.Node{Element: osm.Element{ID: 1, Tags: osm.Tags{"amenity": "cafe", "name": "Foo"}}}
+	rows := insertNode(t, node)
+	if !reflect.DeepEqual(calls, []string{"first", "second:Filtered Foo"}) {
+		t.Errorf("unexpected calls %v", calls)
+	}
+	// filters only change the tags for their table
+	expected := []insertedRow{
+		{"places", 1, []interface{}{int64(1), "Foo"}},
+		{"pois", 1, []interface{}{int64(1), "Filtered Foo"}},
+	}
+	sortRows(rows)
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("unexpected rows %v", rows)
+	}
+	if node.Tags["name"] != "Foo" {
+		t.Errorf("tags of element modified: %v", node.Tags)
+	}
+
+	calls = nil
+	node.Tags["access"] = "private"
+	rows = insertNode(t, node)
+	// later filters are not called for dropped elements
+	if !reflect.DeepEqual(calls, []string{"first"}) {
+		t.Errorf("unexpected calls %v", calls)
+	}
+	expected = []insertedRow{{"places", 1, []interface{}{int64(1), "Foo"}}}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("unexpected rows %v", rows)
+	}
+}
+
+func TestElementFiltersBeforeRowHooks(t *testing.T) {
+	defer withoutHooks()()
+
+	RegisterElementFilter("pois", func(elem *osm.Element) bool {
+		elem.Tags["name"] = "Filtered"
+		return true
+	})
+	var hookTags []string
+	RegisterRowHook(func(table string, elem *osm.Element, row []interface{}) bool {
+		hookTags = append(hookTags, table+":"+elem.Tags["name"])
+		return true
+	})
+
+	insertNode(t, osm.Node{Element: osm.Element{ID: 1, Tags: osm.Tags{"amenity": "cafe", "name": "Foo"}}})
+	sort.Strings(hookTags)
+	// row hooks receive the filtered element of each table
+	if !reflect.DeepEqual(hookTags, []string{"places:Foo", "pois:Filtered"}) {
+		t.Errorf("unexpected tags in row hooks %v", hookTags)
+	}
+}
+
+func sortRows(rows []insertedRow) {
+	sort.Slice(rows, func(i, j int) bool { return rows[i].table < rows[j].table })
+}
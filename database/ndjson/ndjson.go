@@ -0,0 +1,182 @@
+/*
+Package ndjson implements a database backend that writes all rows as
+newline delimited JSON to stdout.
+
+Each line is a JSON object with the table name and all columns of the
+mapping:
+
+	{"table":"roads","osm_id":4711,"name":"Main Street","geometry":"0102000020110f0000..."}
+
+Geometries are encoded as hex EWKB. Use "ndjson:" as connection to write all
+tables, or "ndjson:roads,buildings" to limit the output to these tables.
+*/
+package ndjson
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/database"
+	"github.com/omniscale/imposm3/geom"
+	"github.com/omniscale/imposm3/log"
+	"github.com/omniscale/imposm3/mapping"
+	"github.com/omniscale/imposm3/mapping/config"
+	"github.com/pkg/errors"
+)
+
+type table struct {
+	name     string
+	columns  []string
+	geometry []bool
+}
+
+type NDJSON struct {
+	mu     sync.Mutex
+	w      *bufio.Writer
+	tables map[string]*table
+	buf    []byte
+}
+
+func New(conf database.Config, m *config.Mapping) (database.DB, error) {
+	params := strings.TrimSpace(strings.TrimPrefix(conf.ConnectionParams, "ndjson:"))
+
+	tables := make(map[string]*table)
+	for name, t := range m.Tables {
+		tbl := &table{name: name}
+		for _, col := range t.Columns {
+			colType, err := mapping.MakeColumnType(col)
+			if err != nil {
+				return nil, errors.Wrapf(err, "creating column %q of table %q", col.Name, name)
+			}
+			tbl.columns = append(tbl.columns, col.Name)
+			tbl.geometry = append(tbl.geometry, colType.GoType == "geometry" || colType.GoType == "validated_geometry")
+		}
+		tables[name] = tbl
+	}
+
+	if params != "" {
+		selected := make(map[string]*table)
+		for _, name := range strings.Split(params, ",") {
+			name = strings.TrimSpace(name)
+			tbl, ok := tables[name]
+			if !ok {
+				return nil, errors.Errorf("unknown table %q in connection", name)
+			}
+			selected[name] = tbl
+		}
+		tables = selected
+	}
+	if len(m.GeneralizedTables) > 0 {
+		log.Println("[warn] Generalized tables are not written to ndjson")
+	}
+
+	return &NDJSON{
+		w:      bufio.NewWriterSize(os.Stdout, 1024*1024),
+		tables: tables,
+	}, nil
+}
+
+func (n *NDJSON) Init() error  { return nil }
+func (n *NDJSON) Begin() error { return nil }
+func (n *NDJSON) Abort() error { return n.w.Flush() }
+func (n *NDJSON) Close() error { return n.w.Flush() }
+
+func (n *NDJSON) End() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.w.Flush()
+}
+
+// Generalize, EnableGeneralizeUpdates and GeneralizeUpdates are no-ops, as
+// generalized tables are not supported.
+func (n *NDJSON) Generalize() error        { return nil }
+func (n *NDJSON) EnableGeneralizeUpdates() {}
+func (n *NDJSON) GeneralizeUpdates() error { return nil }
+
+// Finish is a no-op, as there are no indices.
+func (n *NDJSON) Finish() error { return nil }
+
+func (n *NDJSON) InsertPoint(elem osm.Element, geom geom.Geometry, matches []mapping.Match) error {
+	return n.insert(elem, geom, matches)
+}
+
+func (n *NDJSON) InsertLineString(elem osm.Element, geom geom.Geometry, matches []mapping.Match) error {
+	return n.insert(elem, geom, matches)
+}
+
+func (n *NDJSON) InsertPolygon(elem osm.Element, geom geom.Geometry, matches []mapping.Match) error {
+	return n.insert(elem, geom, matches)
+}
+
+func (n *NDJSON) insert(elem osm.Element, geom geom.Geometry, matches []mapping.Match) error {
+	return database.InsertRows(elem, geom, n.selected(matches), n.insertRow)
+}
+
+func (n *NDJSON) InsertRelationMember(rel osm.Relation, m osm.Member, mi int, geom geom.Geometry, matches []mapping.Match) error {
+	return database.InsertMemberRows(rel, m, mi, geom, n.selected(matches), n.insertRow)
+}
+
+// selected returns the matches of the tables that are selected in the
+// connection, so that filters and hooks are not called for other tables.
+func (n *NDJSON) selected(matches []mapping.Match) []mapping.Match {
+	for i, match := range matches {
+		if _, ok := n.tables[match.Table.Name]; ok {
+			continue
+		}
+		result := append([]mapping.Match(nil), matches[:i]...)
+		for _, match := range matches[i+1:] {
+			if _, ok := n.tables[match.Table.Name]; ok {
+				result = append(result, match)
+			}
+		}
+		return result
+	}
+	return matches
+}
+
+func (n *NDJSON) insertRow(table string, elem *osm.Element, row []interface{}) error {
+	return n.write(n.tables[table], row)
+}
+
+// write encodes row as a single line. Lines of concurrent inserts are never
+// interleaved.
+func (n *NDJSON) write(tbl *table, row []interface{}) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	b := append(n.buf[:0], `{"table":`...)
+	b = appendJSON(b, tbl.name)
+	for i, v := range row {
+		b = append(b, ',')
+		b = appendJSON(b, tbl.columns[i])
+		b = append(b, ':')
+		if tbl.geometry[i] && v == "" {
+			// relation rows without geometry
+			v = nil
+		}
+		b = appendJSON(b, v)
+	}
+	b = append(b, '}', '\n')
+	n.buf = b
+
+	if _, err := n.w.Write(b); err != nil {
+		return errors.Wrap(err, "writing ndjson")
+	}
+	return nil
+}
+
+func appendJSON(b []byte, v interface{}) []byte {
+	enc, err := json.Marshal(v)
+	if err != nil {
+		return append(b, "null"...)
+	}
+	return append(b, enc...)
+}
+
+func init() {
+	database.Register("ndjson", New)
+}
@@ -0,0 +1,81 @@
+package ndjson
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/database"
+	"github.com/omniscale/imposm3/geom"
+	"github.com/omniscale/imposm3/mapping"
+)
+
+const testMapping = `
+tables:
+  pois:
+    type: point
+    columns:
+    - name: osm_id
+      type: id
+    - name: name
+      key: name
+      type: string
+    - name: population
+      key: population
+      type: integer
+    - name: geometry
+      type: geometry
+    mapping:
+      place: [city]
+  roads:
+    type: linestring
+    columns:
+    - name: osm_id
+      type: id
+    mapping:
+      highway: [__any__]
+`
+
+func TestWrite(t *testing.T) {
+	m, err := mapping.New([]byte(testMapping))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := New(database.Config{ConnectionParams: "ndjson:pois"}, &m.Conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := db.(*NDJSON)
+	out := &bytes.Buffer{}
+	n.w = bufio.NewWriter(out)
+
+	node := osm.Node{Element: osm.Element{ID: 1, Tags: osm.Tags{"place": "city", "name": "Foo \"Bar\""}}}
+	g := geom.Geometry{Wkb: []byte("0101000020110f0000")}
+	if err := n.InsertPoint(node.Element, g, m.PointMatcher.MatchNode(&node)); err != nil {
+		t.Fatal(err)
+	}
+	way := osm.Way{Element: osm.Element{ID: 2, Tags: osm.Tags{"highway": "primary"}}, Refs: []int64{1, 2}}
+	if err := n.InsertLineString(way.Element, g, m.LineStringMatcher.MatchWay(&way)); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.End(); err != nil {
+		t.Fatal(err)
+	}
+
+	// roads are not selected in the connection
+	expected := `{"table":"pois","osm_id":1,"name":"Foo \"Bar\"","population":null,"geometry":"0101000020110f0000"}` + "\n"
+	if out.String() != expected {
+		t.Errorf("unexpected output\n%s\nexpected\n%s", out.String(), expected)
+	}
+}
+
+func TestUnknownTable(t *testing.T) {
+	m, err := mapping.New([]byte(testMapping))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(database.Config{ConnectionParams: "ndjson:pois,unknown"}, &m.Conf); err == nil {
+		t.Error("expected error for unknown table")
+	}
+}
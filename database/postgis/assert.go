@@ -0,0 +1,99 @@
+package postgis
+
+import (
+	"fmt"
+	"sort"
+
+	pq "github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// Assert checks the assertions of all tables in the import schema.
+func (pg *PostGIS) Assert() []error {
+	names := make([]string, 0, len(pg.Tables))
+	for name, spec := range pg.Tables {
+		if spec.Assertions != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		errs = append(errs, pg.assertTable(pg.Tables[name])...)
+	}
+	return errs
+}
+
+func (pg *PostGIS) assertTable(spec *TableSpec) []error {
+	a := spec.Assertions
+	table := fmt.Sprintf(`"%s"."%s"`, spec.Schema, spec.FullName)
+	var errs []error
+
+	if a.MinRows > 0 || a.MaxNullGeometry != nil {
+		geomCol := ""
+		for _, col := range spec.Columns {
+			if col.Type.Name() == "GEOMETRY" {
+				geomCol = col.Name
+				break
+			}
+		}
+		nullGeom := "0"
+		if geomCol != "" {
+			nullGeom = fmt.Sprintf(`count(*) FILTER (WHERE "%s" IS NULL OR ST_IsEmpty("%s"))`, geomCol, geomCol)
+		} else if a.MaxNullGeometry != nil {
+			errs = append(errs, errors.Errorf("max_null_geometry of table %s requires a geometry column", spec.Name))
+		}
+		sql := fmt.Sprintf(`SELECT count(*), %s FROM %s`, nullGeom, table)
+		var rows, nulls int64
+		if err := pg.Db.QueryRow(sql).Scan(&rows, &nulls); err != nil {
+			return append(errs, &SQLError{sql, err})
+		}
+		if rows < a.MinRows {
+			errs = append(errs, errors.Errorf("table %s has %d rows, expected at least %d (min_rows)", spec.FullName, rows, a.MinRows))
+		}
+		if a.MaxNullGeometry != nil && geomCol != "" && rows > 0 {
+			if fraction := float64(nulls) / float64(rows); fraction > *a.MaxNullGeometry {
+				errs = append(errs, errors.Errorf("table %s has %.4f rows without geometry, expected at most %.4f (max_null_geometry)", spec.FullName, fraction, *a.MaxNullGeometry))
+			}
+		}
+	}
+
+	cols := make([]string, 0, len(a.RequiredValues))
+	for col := range a.RequiredValues {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	for _, col := range cols {
+		values := a.RequiredValues[col]
+		sql := fmt.Sprintf(`SELECT DISTINCT "%s"::text FROM %s WHERE "%s"::text = ANY($1)`, col, table, col)
+		rows, err := pg.Db.Query(sql, pq.Array(values))
+		if err != nil {
+			errs = append(errs, &SQLError{sql, err})
+			continue
+		}
+		found := make(map[string]bool)
+		for rows.Next() {
+			var v string
+			if err := rows.Scan(&v); err != nil {
+				errs = append(errs, &SQLError{sql, err})
+				break
+			}
+			found[v] = true
+		}
+		if err := rows.Err(); err != nil {
+			errs = append(errs, &SQLError{sql, err})
+		}
+		rows.Close()
+		var missing []string
+		for _, v := range values {
+			if !found[v] {
+				missing = append(missing, v)
+			}
+		}
+		if len(missing) > 0 {
+			errs = append(errs, errors.Errorf("column %s of table %s has no rows with %q (required_values)", col, spec.FullName, missing))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,98 @@
+package postgis
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/omniscale/imposm3/log"
+	"github.com/pkg/errors"
+)
+
+// Check verifies that PostGIS (and hstore, if required by the mapping) is
+// installed and that the current user is allowed to create the tables in all
+// configured schemas.
+func (pg *PostGIS) Check() []error {
+	var errs []error
+
+	tx, err := pg.Db.Begin()
+	if err != nil {
+		return []error{errors.Wrap(err, "begin transaction")}
+	}
+	// we do not modify anything, always rollback
+	defer tx.Rollback()
+
+	version, err := getPostgisVersion(tx)
+	if err != nil {
+		// query error aborts the transaction, nothing else to check
+		return []error{errors.Wrap(err, "PostGIS not available")}
+	}
+	log.Printf("[info] found PostGIS %s", version)
+
+	if pg.requiresHstore() {
+		if err := checkTypeExists(tx, "hstore"); err != nil {
+			errs = append(errs, errors.Wrap(err, "hstore extension required by mapping"))
+		}
+	}
+
+	for _, schema := range []string{
+		pg.Config.ImportSchema,
+		pg.Config.ProductionSchema,
+		pg.Config.BackupSchema,
+	} {
+		if err := checkSchemaCreatePrivilege(tx, schema); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func (pg *PostGIS) requiresHstore() bool {
+	for _, spec := range pg.Tables {
+		for _, col := range spec.Columns {
+			if col.Type.Name() == "HSTORE" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func checkTypeExists(tx *sql.Tx, typeName string) error {
+	sql := fmt.Sprintf("SELECT EXISTS(SELECT 1 FROM pg_type WHERE typname = '%s')", typeName)
+	var exists bool
+	if err := tx.QueryRow(sql).Scan(&exists); err != nil {
+		return &SQLError{sql, err}
+	}
+	if !exists {
+		return errors.Errorf("type %s does not exist", typeName)
+	}
+	return nil
+}
+
+// checkSchemaCreatePrivilege checks that tables can be created in schema, or
+// that the schema itself can be created if it does not exist.
+func checkSchemaCreatePrivilege(tx *sql.Tx, schema string) error {
+	sql := fmt.Sprintf("SELECT EXISTS(SELECT schema_name FROM information_schema.schemata WHERE schema_name = '%s')",
+		schema)
+	var exists bool
+	if err := tx.QueryRow(sql).Scan(&exists); err != nil {
+		return &SQLError{sql, err}
+	}
+
+	var allowed bool
+	if exists {
+		sql = fmt.Sprintf("SELECT has_schema_privilege('%s', 'CREATE')", schema)
+	} else {
+		sql = "SELECT has_database_privilege(current_database(), 'CREATE')"
+	}
+	if err := tx.QueryRow(sql).Scan(&allowed); err != nil {
+		return &SQLError{sql, err}
+	}
+	if !allowed {
+		if exists {
+			return errors.Errorf("missing CREATE privilege for schema %q", schema)
+		}
+		return errors.Errorf("missing CREATE privilege to create schema %q", schema)
+	}
+	return nil
+}
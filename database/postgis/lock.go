@@ -0,0 +1,40 @@
+package postgis
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// TryLock acquires a session level advisory lock for name. The lock is
+// bound to a dedicated connection and released by Postgres when this
+// connection is closed or lost.
+func (pg *PostGIS) TryLock(name string) (bool, error) {
+	ctx := context.Background()
+	if pg.lockConn == nil {
+		conn, err := pg.Db.Conn(ctx)
+		if err != nil {
+			return false, errors.Wrap(err, "opening connection for lock")
+		}
+		pg.lockConn = conn
+	}
+	var locked bool
+	err := pg.lockConn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(hashtext($1))", name).Scan(&locked)
+	if err != nil {
+		pg.lockConn.Close()
+		pg.lockConn = nil
+		return false, errors.Wrapf(err, "acquiring lock %s", name)
+	}
+	return locked, nil
+}
+
+// CheckLock verifies that the connection of the lock is still alive.
+func (pg *PostGIS) CheckLock() error {
+	if pg.lockConn == nil {
+		return errors.New("lock not acquired")
+	}
+	if err := pg.lockConn.PingContext(context.Background()); err != nil {
+		return errors.Wrap(err, "checking lock connection")
+	}
+	return nil
+}
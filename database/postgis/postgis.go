@@ -6,7 +6,6 @@ import (
 	"runtime"
 	"strings"
 	"sync"
-	"sync/atomic"
 
 	pq "github.com/lib/pq"
 	osm "github.com/omniscale/go-osm"
@@ -51,37 +50,46 @@ func createTable(tx *sql.Tx, spec TableSpec) error {
 		return &SQLError{sql, err}
 	}
 
-	err = addGeometryColumn(tx, spec.FullName, spec)
+	err = addGeometryColumns(tx, spec.FullName, spec)
 	if err != nil {
 		return err
 	}
-	return nil
+	return addComments(tx, commentSQL(spec.Schema, spec.FullName, spec.Description, spec.Columns))
 }
 
-func addGeometryColumn(tx *sql.Tx, tableName string, spec TableSpec) error {
-	colName := ""
-	for _, col := range spec.Columns {
-		if col.Type.Name() == "GEOMETRY" {
-			colName = col.Name
-			break
+func addComments(tx *sql.Tx, stmts []string) error {
+	for _, sql := range stmts {
+		if _, err := tx.Exec(sql); err != nil {
+			return &SQLError{sql, err}
 		}
 	}
+	return nil
+}
 
-	if colName == "" {
-		return nil
+// addGeometryColumns adds all geometry columns of spec. Centroid columns
+// are always points, all other columns have the type of the table.
+func addGeometryColumns(tx *sql.Tx, tableName string, spec TableSpec) error {
+	tableGeomType := strings.ToUpper(spec.GeometryType)
+	if tableGeomType == "POLYGON" {
+		tableGeomType = "GEOMETRY" // for multipolygon support
 	}
 
-	geomType := strings.ToUpper(spec.GeometryType)
-	if geomType == "POLYGON" {
-		geomType = "GEOMETRY" // for multipolygon support
-	}
-	sql := fmt.Sprintf("SELECT AddGeometryColumn('%s', '%s', '%s', '%d', '%s', 2);",
-		spec.Schema, tableName, colName, spec.Srid, geomType)
-	row := tx.QueryRow(sql)
-	var void interface{}
-	err := row.Scan(&void)
-	if err != nil {
-		return &SQLError{sql, err}
+	for _, col := range spec.Columns {
+		if col.Type.Name() != "GEOMETRY" {
+			continue
+		}
+		geomType := tableGeomType
+		if col.FieldType.Name == "centroid" {
+			geomType = "POINT"
+		}
+		sql := fmt.Sprintf("SELECT AddGeometryColumn('%s', '%s', '%s', '%d', '%s', 2);",
+			spec.Schema, tableName, col.Name, spec.Srid, geomType)
+		row := tx.QueryRow(sql)
+		var void interface{}
+		err := row.Scan(&void)
+		if err != nil {
+			return &SQLError{sql, err}
+		}
 	}
 	return nil
 }
@@ -165,10 +173,7 @@ func (pg *PostGIS) Init() error {
 func (pg *PostGIS) Finish() error {
 	defer log.Step("Creating geometry indices")()
 
-	worker := int(runtime.GOMAXPROCS(0))
-	if worker < 1 {
-		worker = 1
-	}
+	worker := pg.workers()
 
 	p := newWorkerPool(worker, len(pg.Tables)+len(pg.GeneralizedTables))
 	for _, tbl := range pg.Tables {
@@ -203,10 +208,17 @@ func createIndex(pg *PostGIS, tableName string, columns []ColumnSpec, generalize
 		}
 	}
 
+	firstGeom := true
 	for _, col := range columns {
 		if col.Type.Name() == "GEOMETRY" {
-			sql := fmt.Sprintf(`CREATE INDEX "%s_geom" ON "%s"."%s" USING GIST ("%s")`,
-				tableName, pg.Config.ImportSchema, tableName, col.Name)
+			indexName := tableName + "_geom"
+			if !firstGeom {
+				// keep the old index name for the first geometry column
+				indexName = tableName + "_" + col.Name + "_geom"
+			}
+			firstGeom = false
+			sql := fmt.Sprintf(`CREATE INDEX "%s" ON "%s"."%s" USING GIST ("%s")`,
+				indexName, pg.Config.ImportSchema, tableName, col.Name)
 			step := log.Step(fmt.Sprintf("Creating geometry index on %s", tableName))
 			_, err := pg.Db.Exec(sql)
 			step()
@@ -233,71 +245,132 @@ func createIndex(pg *PostGIS, tableName string, columns []ColumnSpec, generalize
 	return nil
 }
 
-func (pg *PostGIS) GeneralizeUpdates() error {
-	defer log.Step("Updating generalized tables")()
+// updatedIDsFlushSize is the number of updated IDs of a generalized table
+// that are collected before they are applied, to limit the memory during
+// large diff imports.
+const updatedIDsFlushSize = 50000
+
+// addUpdatedID marks id for the update of all generalized tables of matches.
+// All pending IDs are applied as soon as any table reaches the
+// updatedIDsFlushSize, as generalized tables can depend on other generalized
+// tables.
+func (pg *PostGIS) addUpdatedID(id int64, matches []mapping.Match) error {
+	genMatches := pg.generalizedFromMatches(matches)
+	if len(genMatches) == 0 {
+		return nil
+	}
+	pg.updateIDsMu.Lock()
+	flush := false
+	for _, generalizedTable := range genMatches {
+		ids, ok := pg.updatedIDs[generalizedTable.Name]
+		if !ok {
+			ids = make(map[int64]struct{})
+			pg.updatedIDs[generalizedTable.Name] = ids
+		}
+		ids[id] = struct{}{}
+		if len(ids) >= updatedIDsFlushSize {
+			flush = true
+		}
+	}
+	pending := pg.takeUpdatedIDs(flush)
+	pg.updateIDsMu.Unlock()
+
+	if pending == nil {
+		return nil
+	}
+	return pg.applyUpdatedIDs(pending)
+}
+
+// takeUpdatedIDs returns all pending IDs and resets them if take is true.
+// Requires updateIDsMu.
+func (pg *PostGIS) takeUpdatedIDs(take bool) map[string]map[int64]struct{} {
+	if !take || len(pg.updatedIDs) == 0 {
+		return nil
+	}
+	pending := pg.updatedIDs
+	pg.updatedIDs = make(map[string]map[int64]struct{})
+	return pending
+}
+
+// applyUpdatedIDs applies the pending IDs to all generalized tables,
+// sources before the tables that are generalized from them. Updates are
+// serialized, so that the IDs of a later flush are never applied before
+// the IDs of an earlier flush.
+func (pg *PostGIS) applyUpdatedIDs(pending map[string]map[int64]struct{}) error {
+	pg.updateGeneralizedMu.Lock()
+	defer pg.updateGeneralizedMu.Unlock()
 	for _, table := range pg.sortedGeneralizedTables() {
-		if ids, ok := pg.updatedIDs[table]; ok {
-			for _, id := range ids {
-				pg.txRouter.Insert(table, []interface{}{id})
+		if ids, ok := pending[table]; ok {
+			if err := pg.updateGeneralizedIDs(table, ids); err != nil {
+				return err
 			}
 		}
 	}
 	return nil
 }
 
-func (pg *PostGIS) Generalize() error {
-	defer log.Step("Creating generalized tables")()
-
-	worker := int(runtime.GOMAXPROCS(0))
-	if worker < 1 {
-		worker = 1
-	}
-	// generalized tables can depend on other generalized tables
-	// create tables with non-generalized sources first
-	p := newWorkerPool(worker, len(pg.GeneralizedTables))
-	for _, table := range pg.GeneralizedTables {
-		if table.SourceGeneralized == nil {
-			tbl := table // for following closure
-			p.in <- func() error {
-				if err := pg.generalizeTable(tbl); err != nil {
-					return err
-				}
-				tbl.created = true
-				return nil
-			}
+// updateGeneralizedIDs recreates the rows of all ids in the generalized
+// table. Rows are deleted first, as an id can be added again after it was
+// already flushed.
+func (pg *PostGIS) updateGeneralizedIDs(table string, ids map[int64]struct{}) error {
+	for id := range ids {
+		if err := pg.txRouter.Delete(table, id); err != nil {
+			return errors.Wrapf(err, "deleting %d from %q", id, table)
+		}
+		if err := pg.txRouter.Insert(table, []interface{}{id}); err != nil {
+			return errors.Wrapf(err, "updating %d in %q", id, table)
 		}
 	}
-	err := p.wait()
-	if err != nil {
-		return err
+	return nil
+}
+
+func (pg *PostGIS) GeneralizeUpdates() error {
+	defer log.Step("Updating generalized tables")()
+	pg.updateIDsMu.Lock()
+	pending := pg.takeUpdatedIDs(true)
+	pg.updateIDsMu.Unlock()
+	if pending == nil {
+		return nil
 	}
+	return pg.applyUpdatedIDs(pending)
+}
 
-	// next create tables with created generalized sources until
-	// no new source is created
-	created := int32(1)
-	for created == 1 {
-		created = 0
+func (pg *PostGIS) Generalize() error {
+	defer log.Step("Creating generalized tables")()
 
-		p := newWorkerPool(worker, len(pg.GeneralizedTables))
-		for _, table := range pg.GeneralizedTables {
-			if !table.created && table.SourceGeneralized.created {
-				tbl := table // for following closure
-				p.in <- func() error {
-					if err := pg.generalizeTable(tbl); err != nil {
-						return err
-					}
-					tbl.created = true
-					atomic.StoreInt32(&created, 1)
-					return nil
-				}
-			}
-		}
-		err := p.wait()
+	// Generalized tables can depend on other generalized tables. Each table
+	// is created as soon as its source is created, so that independent
+	// tables don't wait for each other. sem limits the concurrent queries
+	// to the number of workers.
+	sem := make(chan struct{}, pg.workers())
+	errc := make(chan error, len(pg.GeneralizedTables))
+	var wg sync.WaitGroup
+
+	var generalize func(table *GeneralizedTableSpec)
+	generalize = func(table *GeneralizedTableSpec) {
+		defer wg.Done()
+		sem <- struct{}{}
+		err := pg.generalizeTable(table)
+		<-sem
 		if err != nil {
-			return err
+			errc <- err
+			return
+		}
+		for _, dependent := range table.Generalizations {
+			wg.Add(1)
+			go generalize(dependent)
 		}
 	}
-	return nil
+
+	for _, table := range pg.GeneralizedTables {
+		if table.SourceGeneralized == nil {
+			wg.Add(1)
+			go generalize(table)
+		}
+	}
+	wg.Wait()
+	close(errc)
+	return <-errc
 }
 
 func (pg *PostGIS) generalizeTable(table *GeneralizedTableSpec) error {
@@ -335,12 +408,27 @@ func (pg *PostGIS) generalizeTable(table *GeneralizedTableSpec) error {
 	sql := fmt.Sprintf(`CREATE TABLE "%s"."%s" AS (SELECT %s FROM "%s"."%s"%s)`,
 		pg.Config.ImportSchema, table.FullName, columnSQL, pg.Config.ImportSchema,
 		sourceTable, where)
+	if table.SQL != "" {
+		sql = fmt.Sprintf(`CREATE TABLE "%s"."%s" AS (%s)`,
+			pg.Config.ImportSchema, table.FullName,
+			table.selectSQL(pg.Config.ImportSchema, sourceTable))
+	}
 
 	_, err = tx.Exec(sql)
 	if err != nil {
 		return &SQLError{sql, err}
 	}
 
+	// columns of custom queries are not known, only comment the table
+	var columns []ColumnSpec
+	if table.SQL == "" {
+		columns = table.Source.Columns
+	}
+	err = addComments(tx, commentSQL(pg.Config.ImportSchema, table.FullName, table.Description, columns))
+	if err != nil {
+		return err
+	}
+
 	postgisVersion, err := getPostgisVersion(tx)
 	if err != nil {
 		return errors.Wrap(err, "detecting PostGIS version")
@@ -364,10 +452,7 @@ func (pg *PostGIS) generalizeTable(table *GeneralizedTableSpec) error {
 func (pg *PostGIS) Optimize() error {
 	defer log.Step("Clustering on geometry")()
 
-	worker := int(runtime.GOMAXPROCS(0))
-	if worker < 1 {
-		worker = 1
-	}
+	worker := pg.workers()
 
 	p := newWorkerPool(worker, len(pg.Tables)+len(pg.GeneralizedTables))
 
@@ -437,11 +522,34 @@ type PostGIS struct {
 	Tables                  map[string]*TableSpec
 	GeneralizedTables       map[string]*GeneralizedTableSpec
 	Prefix                  string
+	Suffix                  string
 	txRouter                *TxRouter
 	updateGeneralizedTables bool
 
 	updateIDsMu sync.Mutex
-	updatedIDs  map[string][]int64
+	updatedIDs  map[string]map[int64]struct{}
+	// updateGeneralizedMu serializes the updates of generalized tables.
+	updateGeneralizedMu sync.Mutex
+
+	stats      *runStats
+	quarantine *quarantine
+
+	deployedTables []string
+
+	lockConn *sql.Conn
+}
+
+// workers returns the number of concurrent database connections for
+// indexing, generalizing and clustering.
+func (pg *PostGIS) workers() int {
+	worker := pg.Config.Workers
+	if worker <= 0 {
+		worker = int(runtime.GOMAXPROCS(0))
+	}
+	if worker < 1 {
+		worker = 1
+	}
+	return worker
 }
 
 func (pg *PostGIS) Open() error {
@@ -459,65 +567,54 @@ func (pg *PostGIS) Open() error {
 	return nil
 }
 
+func (pg *PostGIS) Ping() error {
+	return pg.Db.Ping()
+}
+
 func (pg *PostGIS) InsertPoint(elem osm.Element, geom geom.Geometry, matches []mapping.Match) error {
-	for _, match := range matches {
-		row := match.Row(&elem, &geom)
-		if err := pg.txRouter.Insert(match.Table.Name, row); err != nil {
-			return err
-		}
-	}
-	return nil
+	return database.InsertRows(elem, geom, matches, pg.insertRow)
 }
 
 func (pg *PostGIS) InsertLineString(elem osm.Element, geom geom.Geometry, matches []mapping.Match) error {
-	for _, match := range matches {
-		row := match.Row(&elem, &geom)
-		if err := pg.txRouter.Insert(match.Table.Name, row); err != nil {
-			return err
-		}
+	if err := database.InsertRows(elem, geom, matches, pg.insertRow); err != nil {
+		return err
 	}
 	if pg.updateGeneralizedTables {
-		genMatches := pg.generalizedFromMatches(matches)
-		if len(genMatches) > 0 {
-			pg.updateIDsMu.Lock()
-			for _, generalizedTable := range genMatches {
-				pg.updatedIDs[generalizedTable.Name] = append(pg.updatedIDs[generalizedTable.Name], elem.ID)
-
-			}
-			pg.updateIDsMu.Unlock()
+		if err := pg.addUpdatedID(elem.ID, matches); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
 func (pg *PostGIS) InsertPolygon(elem osm.Element, geom geom.Geometry, matches []mapping.Match) error {
-	for _, match := range matches {
-		row := match.Row(&elem, &geom)
-		if err := pg.txRouter.Insert(match.Table.Name, row); err != nil {
-			return err
-		}
+	if err := database.InsertRows(elem, geom, matches, pg.insertRow); err != nil {
+		return err
 	}
 	if pg.updateGeneralizedTables {
-		genMatches := pg.generalizedFromMatches(matches)
-		if len(genMatches) > 0 {
-			pg.updateIDsMu.Lock()
-			for _, generalizedTable := range genMatches {
-				pg.updatedIDs[generalizedTable.Name] = append(pg.updatedIDs[generalizedTable.Name], elem.ID)
-
-			}
-			pg.updateIDsMu.Unlock()
+		if err := pg.addUpdatedID(elem.ID, matches); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
 func (pg *PostGIS) InsertRelationMember(rel osm.Relation, m osm.Member, mi int, geom geom.Geometry, matches []mapping.Match) error {
-	for _, match := range matches {
-		row := match.MemberRow(&rel, &m, mi, &geom)
-		if err := pg.txRouter.Insert(match.Table.Name, row); err != nil {
-			return err
-		}
+	return database.InsertMemberRows(rel, m, mi, geom, matches, pg.insertRow)
+}
+
+// insertRow inserts row into table. Rows with invalid values are not
+// inserted, but reported as failed.
+func (pg *PostGIS) insertRow(table string, elem *osm.Element, row []interface{}) error {
+	if err := checkRow(pg.Tables[table], row); err != nil {
+		log.Printf("[warn]: element %d of %s: %s", elem.ID, table, err)
+		pg.rowFailed(table, *elem, err)
+		return nil
+	}
+	if err := pg.txRouter.Insert(table, row); err != nil {
+		return err
 	}
+	pg.stats.inserted(table)
 	return nil
 }
 
@@ -526,6 +623,7 @@ func (pg *PostGIS) Delete(id int64, matches []mapping.Match) error {
 		if err := pg.txRouter.Delete(match.Table.Name, id); err != nil {
 			return errors.Wrapf(err, "deleting %d from %q", id, match.Table.Name)
 		}
+		pg.stats.deleted(match.Table.Name)
 	}
 	if pg.updateGeneralizedTables {
 		for _, generalizedTable := range pg.generalizedFromMatches(matches) {
@@ -546,6 +644,8 @@ func (pg *PostGIS) generalizedFromMatches(matches []mapping.Match) []*Generalize
 	return generalizedTables
 }
 
+// sortedGeneralizedTables returns the names of all generalized tables,
+// with each table after the generalized table it is built from.
 func (pg *PostGIS) sortedGeneralizedTables() []string {
 	added := map[string]bool{}
 	sorted := []string{}
@@ -553,7 +653,7 @@ func (pg *PostGIS) sortedGeneralizedTables() []string {
 	for len(pg.GeneralizedTables) > len(sorted) {
 		for _, tbl := range pg.GeneralizedTables {
 			if _, ok := added[tbl.Name]; !ok {
-				if tbl.Source != nil || added[tbl.SourceGeneralized.Name] {
+				if tbl.SourceGeneralized == nil || added[tbl.SourceGeneralized.Name] {
 					added[tbl.Name] = true
 					sorted = append(sorted, tbl.Name)
 				}
@@ -565,30 +665,47 @@ func (pg *PostGIS) sortedGeneralizedTables() []string {
 
 func (pg *PostGIS) EnableGeneralizeUpdates() {
 	pg.updateGeneralizedTables = true
-	pg.updatedIDs = make(map[string][]int64)
+	pg.updatedIDs = make(map[string]map[int64]struct{})
 }
 
 func (pg *PostGIS) Begin() error {
 	var err error
 	pg.txRouter, err = newTxRouter(pg, false)
+	pg.stats.begin()
 	return err
 }
 
 func (pg *PostGIS) BeginBulk() error {
 	var err error
 	pg.txRouter, err = newTxRouter(pg, true)
+	pg.stats.begin()
 	return err
 }
 
 func (pg *PostGIS) Abort() error {
+	if pg.quarantine != nil {
+		if err := pg.quarantine.close(); err != nil {
+			log.Println("[error] Closing quarantine:", err)
+		}
+	}
 	return pg.txRouter.Abort()
 }
 
 func (pg *PostGIS) End() error {
-	return pg.txRouter.End()
+	defer pg.stats.end()
+	if err := pg.txRouter.End(); err != nil {
+		return err
+	}
+	if pg.quarantine != nil {
+		return errors.Wrap(pg.quarantine.close(), "closing quarantine")
+	}
+	return nil
 }
 
 func (pg *PostGIS) Close() error {
+	if pg.lockConn != nil {
+		pg.lockConn.Close()
+	}
 	return pg.Db.Close()
 }
 
@@ -625,6 +742,18 @@ func New(conf database.Config, m *config.Mapping) (database.DB, error) {
 
 	params = disableDefaultSsl(params)
 	params, db.Prefix = stripPrefixFromConnectionParams(params)
+	if conf.TablePrefix != "" {
+		db.Prefix = conf.TablePrefix
+		if db.Prefix[len(db.Prefix)-1] != '_' {
+			db.Prefix = db.Prefix + "_"
+		}
+	}
+	if conf.TableSuffix != "" {
+		db.Suffix = conf.TableSuffix
+		if db.Suffix[0] != '_' {
+			db.Suffix = "_" + db.Suffix
+		}
+	}
 
 	for name, table := range m.Tables {
 		db.Tables[name], err = NewTableSpec(db, table)
@@ -633,12 +762,19 @@ func New(conf database.Config, m *config.Mapping) (database.DB, error) {
 		}
 	}
 	for name, table := range m.GeneralizedTables {
+		if len(table.SQL) > 0 && table.SQL["postgis"] == "" {
+			return nil, errors.Errorf("missing postgis query in sql of generalized table %q", name)
+		}
 		db.GeneralizedTables[name] = NewGeneralizedTableSpec(db, table)
 	}
 	if err := db.prepareGeneralizedTableSources(); err != nil {
 		return nil, errors.Wrap(err, "preparing generalized table sources")
 	}
 	db.prepareGeneralizations()
+	db.stats = newRunStats(db.Tables)
+	if conf.QuarantineDir != "" {
+		db.quarantine = newQuarantine(conf.QuarantineDir)
+	}
 
 	db.Params = params
 	err = db.Open()
@@ -0,0 +1,49 @@
+package postgis
+
+import (
+	"testing"
+)
+
+func TestSortedGeneralizedTables(t *testing.T) {
+	pg := &PostGIS{
+		Tables: map[string]*TableSpec{
+			"roads": {Name: "roads"},
+		},
+		GeneralizedTables: map[string]*GeneralizedTableSpec{},
+	}
+	// chain of generalized tables built on each other, and a second
+	// table of the original source
+	for name, source := range map[string]string{
+		"roads_gen0": "roads",
+		"roads_gen1": "roads_gen0",
+		"roads_gen2": "roads_gen1",
+		"roads_gen3": "roads_gen2",
+		"roads_gen4": "roads_gen3",
+		"roads_alt":  "roads",
+	} {
+		pg.GeneralizedTables[name] = &GeneralizedTableSpec{Name: name, SourceName: source}
+	}
+	if err := pg.prepareGeneralizedTableSources(); err != nil {
+		t.Fatal(err)
+	}
+
+	// map order is random, check multiple times
+	for i := 0; i < 20; i++ {
+		sorted := pg.sortedGeneralizedTables()
+		if len(sorted) != len(pg.GeneralizedTables) {
+			t.Fatalf("unexpected tables %v", sorted)
+		}
+		pos := map[string]int{}
+		for i, name := range sorted {
+			pos[name] = i
+		}
+		for name, tbl := range pg.GeneralizedTables {
+			if tbl.SourceGeneralized == nil {
+				continue
+			}
+			if pos[tbl.SourceGeneralized.Name] > pos[name] {
+				t.Fatalf("%s before its source %s in %v", name, tbl.SourceGeneralized.Name, sorted)
+			}
+		}
+	}
+}
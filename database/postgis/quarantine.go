@@ -0,0 +1,150 @@
+package postgis
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/log"
+	"github.com/pkg/errors"
+)
+
+// minRowsForErrorRate is the number of rows of a table that are required
+// before the MaxErrorRate is checked, so that a few errors at the beginning
+// do not abort the import.
+const minRowsForErrorRate = 1000
+
+// quarantineRecord is written as a single JSON line for each element that
+// could not be inserted.
+type quarantineRecord struct {
+	ID    int64    `json:"id"`
+	Tags  osm.Tags `json:"tags"`
+	Error string   `json:"error"`
+}
+
+// quarantine writes elements that could not be inserted into one file per
+// table in dir.
+type quarantine struct {
+	dir   string
+	mu    sync.Mutex
+	files map[string]*quarantineFile
+}
+
+type quarantineFile struct {
+	f     *os.File
+	w     *bufio.Writer
+	count int
+}
+
+func newQuarantine(dir string) *quarantine {
+	return &quarantine{dir: dir, files: make(map[string]*quarantineFile)}
+}
+
+// add appends elem to the quarantine file of table. Files are opened in
+// append mode, so that the records of all diff imports are kept.
+func (q *quarantine) add(table string, elem osm.Element, err error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	qf, ok := q.files[table]
+	if !ok {
+		if err := os.MkdirAll(q.dir, 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(filepath.Join(q.dir, table+".ndjson"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		qf = &quarantineFile{f: f, w: bufio.NewWriter(f)}
+		q.files[table] = qf
+	}
+	b, jerr := json.Marshal(quarantineRecord{ID: elem.ID, Tags: elem.Tags, Error: err.Error()})
+	if jerr != nil {
+		return jerr
+	}
+	qf.count++
+	_, werr := qf.w.Write(append(b, '\n'))
+	return werr
+}
+
+// close flushes and closes all files and logs the number of quarantined
+// elements for each table.
+func (q *quarantine) close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	tables := make([]string, 0, len(q.files))
+	for table := range q.files {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	var firstErr error
+	for _, table := range tables {
+		qf := q.files[table]
+		log.Printf("[warn] %d elements of %s could not be inserted, see %s", qf.count, table, qf.f.Name())
+		if err := qf.w.Flush(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if err := qf.f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	q.files = make(map[string]*quarantineFile)
+	return firstErr
+}
+
+// checkRow returns an error for values that PostgreSQL rejects. COPY aborts
+// the whole bulk import on these values, so they need to be checked before
+// they are inserted.
+func checkRow(spec *TableSpec, row []interface{}) error {
+	for i, v := range row {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		if strings.IndexByte(s, 0) != -1 {
+			return errors.Errorf("NUL character in column %s", spec.Columns[i].Name)
+		}
+		if !utf8.ValidString(s) {
+			return errors.Errorf("invalid UTF-8 in column %s", spec.Columns[i].Name)
+		}
+	}
+	return nil
+}
+
+// rowFailed handles an element of table that could not be inserted. It
+// counts the error, writes the element to the quarantine and exits if the
+// MaxErrorRate is exceeded.
+func (pg *PostGIS) rowFailed(table string, elem osm.Element, err error) {
+	spec, ok := pg.Tables[table]
+	if !ok {
+		return
+	}
+	pg.stats.failed(table)
+	if pg.quarantine != nil {
+		if qerr := pg.quarantine.add(spec.FullName, elem, err); qerr != nil {
+			log.Printf("[error] Writing quarantine for %s: %s", table, qerr)
+		}
+	}
+	if pg.Config.MaxErrorRate <= 0 {
+		return
+	}
+	ts, ok := pg.stats.tables[table]
+	if !ok {
+		return
+	}
+	failed := atomic.LoadInt64(&ts.errors)
+	total := failed + atomic.LoadInt64(&ts.inserted)
+	if total >= minRowsForErrorRate && float64(failed)/float64(total) > pg.Config.MaxErrorRate {
+		log.Fatalf("[fatal] %d of %d elements of %s could not be inserted, more than max error rate of %g",
+			failed, total, spec.FullName, pg.Config.MaxErrorRate)
+	}
+}
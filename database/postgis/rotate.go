@@ -23,8 +23,9 @@ func (pg *PostGIS) rotate(source, dest, backup string) error {
 	}
 	defer rollbackIfTx(&tx)
 
+	var rotated []string
 	for _, tableName := range pg.tableNames() {
-		tableName = pg.Prefix + tableName
+		tableName = pg.fullName(tableName)
 
 		log.Printf("[info] Rotating %s from %s -> %s -> %s", tableName, source, dest, backup)
 
@@ -66,6 +67,7 @@ func (pg *PostGIS) rotate(source, dest, backup string) error {
 		if err != nil {
 			return err
 		}
+		rotated = append(rotated, tableName)
 	}
 
 	err = tx.Commit()
@@ -73,9 +75,14 @@ func (pg *PostGIS) rotate(source, dest, backup string) error {
 		return err
 	}
 	tx = nil // set nil to prevent rollback
+	pg.deployedTables = rotated
 	return nil
 }
 
+func (pg *PostGIS) DeployedTables() []string {
+	return pg.deployedTables
+}
+
 func (pg *PostGIS) Deploy() error {
 	return pg.rotate(pg.Config.ImportSchema, pg.Config.ProductionSchema, pg.Config.BackupSchema)
 }
@@ -94,7 +101,7 @@ func (pg *PostGIS) RemoveBackup() error {
 	backup := pg.Config.BackupSchema
 
 	for _, tableName := range pg.tableNames() {
-		tableName = pg.Prefix + tableName
+		tableName = pg.fullName(tableName)
 
 		backupExists, err := tableExists(tx, backup, tableName)
 		if err != nil {
@@ -118,6 +125,53 @@ func (pg *PostGIS) RemoveBackup() error {
 	return nil
 }
 
+// Drop removes all tables from the import and backup schema, and from the
+// production schema if production is true.
+func (pg *PostGIS) Drop(production bool) error {
+	defer log.Step("Dropping tables")()
+
+	tx, err := pg.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer rollbackIfTx(&tx)
+
+	schemas := []string{pg.Config.ImportSchema, pg.Config.BackupSchema}
+	if production {
+		schemas = append(schemas, pg.Config.ProductionSchema)
+	}
+
+	for _, schema := range schemas {
+		for _, tableName := range pg.tableNames() {
+			tableName = pg.fullName(tableName)
+
+			exists, err := tableExists(tx, schema, tableName)
+			if err != nil {
+				return err
+			}
+			if exists {
+				log.Printf("[info] dropping %s from %s", tableName, schema)
+				err = dropTableIfExists(tx, schema, tableName)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	tx = nil // set nil to prevent rollback
+	return nil
+}
+
+// fullName returns the name of the table with prefix and suffix.
+func (pg *PostGIS) fullName(name string) string {
+	return pg.Prefix + name + pg.Suffix
+}
+
 // tableNames returns a list of all tables (without prefix).
 func (pg *PostGIS) tableNames() []string {
 	var names []string
@@ -18,8 +18,12 @@ func newTxRouter(pg *PostGIS, bulkImport bool) (*TxRouter, error) {
 	}
 
 	if bulkImport {
+		var budget chan struct{}
+		if pg.Config.BulkMaxRows > 0 {
+			budget = make(chan struct{}, pg.Config.BulkMaxRows)
+		}
 		for tableName, table := range pg.Tables {
-			tt := NewBulkTableTx(pg, table)
+			tt := NewBulkTableTx(pg, table, budget)
 			err := tt.Begin(nil)
 			if err != nil {
 				return nil, err
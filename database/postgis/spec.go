@@ -2,8 +2,10 @@ package postgis
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
+	pq "github.com/lib/pq"
 	"github.com/omniscale/imposm3/mapping"
 	"github.com/omniscale/imposm3/mapping/config"
 	"github.com/pkg/errors"
@@ -13,6 +15,8 @@ type ColumnSpec struct {
 	Name      string
 	FieldType mapping.ColumnType
 	Type      ColumnType
+	// Description from the mapping, stored as column comment.
+	Description string
 }
 type TableSpec struct {
 	Name            string
@@ -22,6 +26,13 @@ type TableSpec struct {
 	GeometryType    string
 	Srid            int
 	Generalizations []*GeneralizedTableSpec
+	// IDColumn is the column with the OSM ID for deletes. Empty if the
+	// table has no id column and can't be updated.
+	IDColumn string
+	// Assertions from the mapping, nil if the table has none.
+	Assertions *config.TableAssertions
+	// Description from the mapping, stored as table comment.
+	Description string
 }
 
 type GeneralizedTableSpec struct {
@@ -33,8 +44,15 @@ type GeneralizedTableSpec struct {
 	SourceGeneralized *GeneralizedTableSpec
 	Tolerance         float64
 	Where             string
-	created           bool
 	Generalizations   []*GeneralizedTableSpec
+	// SQL is the custom query from the mapping, with {source} and
+	// {tolerance} placeholders.
+	SQL string
+	// UpdateSQL is the custom query for a single element, with an
+	// additional {id} placeholder.
+	UpdateSQL string
+	// Description from the mapping, stored as table comment.
+	Description string
 }
 
 func (col *ColumnSpec) AsSQL() string {
@@ -83,6 +101,24 @@ func (spec *TableSpec) CreateTableSQL() string {
 	)
 }
 
+// commentSQL returns the COMMENT statements for the description of the
+// table and of all columns with a description.
+func commentSQL(schema, table, description string, columns []ColumnSpec) []string {
+	var stmts []string
+	if description != "" {
+		stmts = append(stmts, fmt.Sprintf(`COMMENT ON TABLE "%s"."%s" IS %s`,
+			schema, table, pq.QuoteLiteral(description)))
+	}
+	for _, col := range columns {
+		if col.Description == "" {
+			continue
+		}
+		stmts = append(stmts, fmt.Sprintf(`COMMENT ON COLUMN "%s"."%s"."%s" IS %s`,
+			schema, table, col.Name, pq.QuoteLiteral(col.Description)))
+	}
+	return stmts
+}
+
 func (spec *TableSpec) InsertSQL() string {
 	var cols []string
 	var vars []string
@@ -116,24 +152,16 @@ func (spec *TableSpec) CopySQL() string {
 	)
 }
 
-func (spec *TableSpec) DeleteSQL() string {
-	var idColumnName string
-	for _, col := range spec.Columns {
-		if col.FieldType.Name == "id" {
-			idColumnName = col.Name
-			break
-		}
-	}
-
-	if idColumnName == "" {
-		panic("missing id column")
+func (spec *TableSpec) DeleteSQL() (string, error) {
+	if spec.IDColumn == "" {
+		return "", errors.Errorf("table %s requires a column with type id for updates", spec.Name)
 	}
 
 	return fmt.Sprintf(`DELETE FROM "%s"."%s" WHERE "%s" = $1`,
 		spec.Schema,
 		spec.FullName,
-		idColumnName,
-	)
+		spec.IDColumn,
+	), nil
 }
 
 func NewTableSpec(pg *PostGIS, t *config.Table) (*TableSpec, error) {
@@ -146,10 +174,12 @@ func NewTableSpec(pg *PostGIS, t *config.Table) (*TableSpec, error) {
 
 	spec := TableSpec{
 		Name:         t.Name,
-		FullName:     pg.Prefix + t.Name,
+		FullName:     pg.fullName(t.Name),
 		Schema:       pg.Config.ImportSchema,
 		GeometryType: geomType,
 		Srid:         pg.Config.Srid,
+		Assertions:   t.Assertions,
+		Description:  t.Description,
 	}
 	for _, column := range t.Columns {
 		columnType, err := mapping.MakeColumnType(column)
@@ -160,55 +190,76 @@ func NewTableSpec(pg *PostGIS, t *config.Table) (*TableSpec, error) {
 		if !ok {
 			return nil, errors.Errorf("unhandled column type %v, using string type", columnType)
 		}
-		col := ColumnSpec{column.Name, *columnType, pgType}
+		col := ColumnSpec{column.Name, *columnType, pgType, column.Description}
 		spec.Columns = append(spec.Columns, col)
+		if columnType.Name == "id" && (t.IDColumn == column.Name || (t.IDColumn == "" && spec.IDColumn == "")) {
+			spec.IDColumn = column.Name
+		}
 	}
 	return &spec, nil
 }
 
 func NewGeneralizedTableSpec(pg *PostGIS, t *config.GeneralizedTable) *GeneralizedTableSpec {
 	spec := GeneralizedTableSpec{
-		Name:       t.Name,
-		FullName:   pg.Prefix + t.Name,
-		Schema:     pg.Config.ImportSchema,
-		Tolerance:  t.Tolerance,
-		Where:      t.SQLFilter,
-		SourceName: t.SourceTableName,
+		Name:        t.Name,
+		FullName:    pg.fullName(t.Name),
+		Schema:      pg.Config.ImportSchema,
+		Tolerance:   t.Tolerance,
+		Where:       t.SQLFilter,
+		SourceName:  t.SourceTableName,
+		SQL:         t.SQL["postgis"],
+		UpdateSQL:   t.UpdateSQL["postgis"],
+		Description: t.Description,
 	}
 	return &spec
 }
 
-func (spec *GeneralizedTableSpec) DeleteSQL() string {
-	var idColumnName string
-	for _, col := range spec.Source.Columns {
-		if col.FieldType.Name == "id" {
-			idColumnName = col.Name
-			break
-		}
-	}
+// selectSQL returns the custom query of the table for the source table in
+// schema.
+func (spec *GeneralizedTableSpec) selectSQL(schema, source string) string {
+	return spec.replacePlaceholders(spec.SQL, schema, source)
+}
+
+// updateSelectSQL returns the custom update query of the table for the
+// source table in schema, with $1 for the id.
+func (spec *GeneralizedTableSpec) updateSelectSQL(schema, source string) string {
+	return strings.Replace(spec.replacePlaceholders(spec.UpdateSQL, schema, source), "{id}", "$1", -1)
+}
+
+func (spec *GeneralizedTableSpec) replacePlaceholders(sql, schema, source string) string {
+	r := strings.NewReplacer(
+		"{source}", fmt.Sprintf(`"%s"."%s"`, schema, source),
+		"{tolerance}", strconv.FormatFloat(spec.Tolerance, 'f', -1, 64),
+	)
+	return r.Replace(sql)
+}
 
-	if idColumnName == "" {
-		panic("missing id column")
+func (spec *GeneralizedTableSpec) DeleteSQL() (string, error) {
+	if spec.Source.IDColumn == "" {
+		return "", errors.Errorf("generalized table %s requires a column with type id in %s for updates", spec.Name, spec.Source.Name)
+	}
+	if spec.SQL != "" && spec.UpdateSQL == "" {
+		// running the custom query for each id is slow and returns wrong
+		// rows for aggregating queries
+		return "", errors.Errorf("generalized table %s with sql requires update_sql for updates", spec.Name)
 	}
 
 	return fmt.Sprintf(`DELETE FROM "%s"."%s" WHERE "%s" = $1`,
 		spec.Schema,
 		spec.FullName,
-		idColumnName,
-	)
+		spec.Source.IDColumn,
+	), nil
 }
 
+// InsertSQL requires a Source with IDColumn, check DeleteSQL first.
 func (spec *GeneralizedTableSpec) InsertSQL() string {
-	var idColumnName string
-	for _, col := range spec.Source.Columns {
-		if col.FieldType.Name == "id" {
-			idColumnName = col.Name
-			break
+	if spec.SQL != "" {
+		source := spec.Source.FullName
+		if spec.SourceGeneralized != nil {
+			source = spec.SourceGeneralized.FullName
 		}
-	}
-
-	if idColumnName == "" {
-		panic("missing id column")
+		return fmt.Sprintf(`INSERT INTO "%s"."%s" (%s)`,
+			spec.Schema, spec.FullName, spec.updateSelectSQL(spec.Source.Schema, source))
 	}
 
 	var cols []string
@@ -216,7 +267,7 @@ func (spec *GeneralizedTableSpec) InsertSQL() string {
 		cols = append(cols, col.Type.GeneralizeSQL(&col, spec))
 	}
 
-	where := fmt.Sprintf(` WHERE "%s" = $1`, idColumnName)
+	where := fmt.Sprintf(` WHERE "%s" = $1`, spec.Source.IDColumn)
 	if spec.Where != "" {
 		where += " AND (" + spec.Where + ")"
 	}
@@ -226,5 +277,4 @@ func (spec *GeneralizedTableSpec) InsertSQL() string {
 		spec.Schema, spec.FullName, columnSQL, spec.Source.Schema,
 		spec.Source.FullName, where)
 	return sql
-
 }
@@ -0,0 +1,112 @@
+package postgis
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGeneralizedTableSpecSQL(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		sql       string
+		updateSQL string
+		selectSQL string
+		updateSel string
+	}{
+		{
+			name:      "source and tolerance",
+			sql:       "SELECT osm_id, ST_Simplify(geometry, {tolerance}) FROM {source}",
+			updateSQL: "SELECT osm_id, ST_Simplify(geometry, {tolerance}) FROM {source} WHERE osm_id = {id}",
+			selectSQL: `SELECT osm_id, ST_Simplify(geometry, 0.5) FROM "import"."osm_roads"`,
+			updateSel: `SELECT osm_id, ST_Simplify(geometry, 0.5) FROM "import"."osm_roads" WHERE osm_id = $1`,
+		},
+		{
+			name:      "multiple placeholders",
+			sql:       "SELECT * FROM {source} a JOIN {source} b ON ST_DWithin(a.geometry, b.geometry, {tolerance})",
+			updateSQL: "SELECT * FROM {source} WHERE osm_id = {id} OR parent = {id}",
+			selectSQL: `SELECT * FROM "import"."osm_roads" a JOIN "import"."osm_roads" b ON ST_DWithin(a.geometry, b.geometry, 0.5)`,
+			updateSel: `SELECT * FROM "import"."osm_roads" WHERE osm_id = $1 OR parent = $1`,
+		},
+		{
+			name:      "id only in updates",
+			sql:       "SELECT {id} FROM {source}",
+			updateSQL: "SELECT {id}",
+			selectSQL: `SELECT {id} FROM "import"."osm_roads"`,
+			updateSel: `SELECT $1`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := GeneralizedTableSpec{Tolerance: 0.5, SQL: tt.sql, UpdateSQL: tt.updateSQL}
+			if sql := spec.selectSQL("import", "osm_roads"); sql != tt.selectSQL {
+				t.Errorf("unexpected selectSQL\n%s\nexpected\n%s", sql, tt.selectSQL)
+			}
+			if sql := spec.updateSelectSQL("import", "osm_roads"); sql != tt.updateSel {
+				t.Errorf("unexpected updateSelectSQL\n%s\nexpected\n%s", sql, tt.updateSel)
+			}
+		})
+	}
+}
+
+func TestReplacePlaceholdersTolerance(t *testing.T) {
+	for _, tt := range []struct {
+		tolerance float64
+		expected  string
+	}{
+		{0, "0"},
+		{50, "50"},
+		{0.0001, "0.0001"},
+		{1e-7, "0.0000001"},
+	} {
+		spec := GeneralizedTableSpec{Tolerance: tt.tolerance}
+		if sql := spec.replacePlaceholders("{tolerance}", "import", "osm_roads"); sql != tt.expected {
+			t.Errorf("unexpected tolerance %s for %v, expected %s", sql, tt.tolerance, tt.expected)
+		}
+	}
+}
+
+func TestGeneralizedTableSpecDeleteSQL(t *testing.T) {
+	source := &TableSpec{Name: "roads", FullName: "osm_roads", IDColumn: "osm_id"}
+	for _, tt := range []struct {
+		name     string
+		spec     GeneralizedTableSpec
+		errMatch string
+	}{
+		{
+			name: "default",
+			spec: GeneralizedTableSpec{Source: source},
+		},
+		{
+			name: "sql with update_sql",
+			spec: GeneralizedTableSpec{Source: source, SQL: "SELECT * FROM {source}", UpdateSQL: "SELECT * FROM {source} WHERE osm_id = {id}"},
+		},
+		{
+			name:     "sql without update_sql",
+			spec:     GeneralizedTableSpec{Source: source, SQL: "SELECT * FROM {source}"},
+			errMatch: "with sql requires update_sql",
+		},
+		{
+			name:     "missing id column",
+			spec:     GeneralizedTableSpec{Source: &TableSpec{Name: "roads"}},
+			errMatch: "requires a column with type id",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.spec.Name = "roads_gen0"
+			tt.spec.Schema = "import"
+			tt.spec.FullName = "osm_roads_gen0"
+			sql, err := tt.spec.DeleteSQL()
+			if tt.errMatch == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if expected := `DELETE FROM "import"."osm_roads_gen0" WHERE "osm_id" = $1`; sql != expected {
+					t.Errorf("unexpected DeleteSQL %s", sql)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMatch) {
+				t.Errorf("expected error with %q, got %v", tt.errMatch, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package postgis
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/omniscale/go-osm/state"
+	"github.com/omniscale/imposm3/log"
+	"github.com/pkg/errors"
+)
+
+func (pg *PostGIS) stateTable() string {
+	return fmt.Sprintf(`"%s"."%s"`, pg.Config.ProductionSchema, pg.Config.StateTable)
+}
+
+// WriteState replaces the state in Config.StateTable of the production
+// schema. WriteState does nothing if no StateTable is configured.
+func (pg *PostGIS) WriteState(s *state.DiffState) error {
+	if pg.Config.StateTable == "" {
+		return nil
+	}
+
+	if err := pg.createSchema(pg.Config.ProductionSchema); err != nil {
+		return err
+	}
+
+	tx, err := pg.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer rollbackIfTx(&tx)
+
+	if err := pg.writeState(tx, s); err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	tx = nil // set nil to prevent rollback
+	if err != nil {
+		return err
+	}
+	log.Printf("[info] wrote state #%d to %s", s.Sequence, pg.stateTable())
+	return nil
+}
+
+// WriteStateTx is like WriteState, but writes the state within the
+// transaction of Begin. The state is committed by End, so that it always
+// matches the imported diff.
+func (pg *PostGIS) WriteStateTx(s *state.DiffState) error {
+	if pg.Config.StateTable == "" {
+		return nil
+	}
+	if pg.txRouter == nil || pg.txRouter.tx == nil {
+		return errors.New("writing state requires a transaction from Begin")
+	}
+
+	if err := pg.createSchema(pg.Config.ProductionSchema); err != nil {
+		return err
+	}
+	return pg.writeState(pg.txRouter.tx, s)
+}
+
+func (pg *PostGIS) writeState(tx *sql.Tx, s *state.DiffState) error {
+	sql := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		sequence BIGINT NOT NULL,
+		time TIMESTAMP WITH TIME ZONE NOT NULL,
+		url VARCHAR NOT NULL,
+		updated TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+	)`, pg.stateTable())
+	if _, err := tx.Exec(sql); err != nil {
+		return &SQLError{sql, err}
+	}
+	sql = fmt.Sprintf(`DELETE FROM %s`, pg.stateTable())
+	if _, err := tx.Exec(sql); err != nil {
+		return &SQLError{sql, err}
+	}
+	sql = fmt.Sprintf(`INSERT INTO %s (sequence, time, url) VALUES ($1, $2, $3)`, pg.stateTable())
+	if _, err := tx.Exec(sql, s.Sequence, s.Time, s.URL); err != nil {
+		return &SQLError{sql, err}
+	}
+	return nil
+}
+
+// ReadState returns the state from Config.StateTable of the production
+// schema, or nil if no StateTable is configured or if it does not exist.
+func (pg *PostGIS) ReadState() (*state.DiffState, error) {
+	if pg.Config.StateTable == "" {
+		return nil, nil
+	}
+	var s state.DiffState
+	query := fmt.Sprintf(`SELECT sequence, time, url FROM %s`, pg.stateTable())
+	err := pg.Db.QueryRow(query).Scan(&s.Sequence, &s.Time, &s.URL)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err, ok := err.(*pq.Error); ok && err.Code == "42P01" {
+		// undefined_table
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &SQLError{query, err}
+	}
+	return &s, nil
+}
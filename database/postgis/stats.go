@@ -0,0 +1,153 @@
+package postgis
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/database"
+	"github.com/omniscale/imposm3/log"
+	"github.com/omniscale/imposm3/mapping"
+)
+
+// tableStats counts the rows of a single table during an import or update.
+// All counters are updated atomically, as writers insert concurrently.
+type tableStats struct {
+	inserted int64
+	deleted  int64
+	errors   int64
+}
+
+// runStats collects statistics for all tables of the current run.
+type runStats struct {
+	tables   map[string]*tableStats
+	started  time.Time
+	duration time.Duration
+}
+
+func newRunStats(tables map[string]*TableSpec) *runStats {
+	s := &runStats{tables: make(map[string]*tableStats)}
+	for name := range tables {
+		s.tables[name] = &tableStats{}
+	}
+	return s
+}
+
+func (s *runStats) begin() {
+	s.started = time.Now()
+}
+
+func (s *runStats) end() {
+	if !s.started.IsZero() {
+		s.duration = time.Since(s.started)
+	}
+}
+
+func (s *runStats) inserted(table string) {
+	if ts, ok := s.tables[table]; ok {
+		atomic.AddInt64(&ts.inserted, 1)
+	}
+}
+
+func (s *runStats) deleted(table string) {
+	if ts, ok := s.tables[table]; ok {
+		atomic.AddInt64(&ts.deleted, 1)
+	}
+}
+
+func (s *runStats) failed(table string) {
+	if ts, ok := s.tables[table]; ok {
+		atomic.AddInt64(&ts.errors, 1)
+	}
+}
+
+// ReportError counts elem as an error for all tables it matched and writes
+// it to the quarantine, if configured.
+func (pg *PostGIS) ReportError(elem osm.Element, matches []mapping.Match, err error) {
+	for _, match := range matches {
+		pg.rowFailed(match.Table.Name, elem, err)
+	}
+}
+
+// TableStats returns the statistics for all tables by their full name.
+func (pg *PostGIS) TableStats() map[string]database.TableStats {
+	result := make(map[string]database.TableStats, len(pg.stats.tables))
+	for name, ts := range pg.stats.tables {
+		result[pg.Tables[name].FullName] = database.TableStats{
+			Inserted: atomic.LoadInt64(&ts.inserted),
+			Deleted:  atomic.LoadInt64(&ts.deleted),
+			Errors:   atomic.LoadInt64(&ts.errors),
+		}
+	}
+	return result
+}
+
+// WriteStats appends the statistics of all tables to Config.StatsTable in
+// the production schema. Tables without any changes are skipped, unless run
+// is "import". WriteStats does nothing if no StatsTable is configured.
+func (pg *PostGIS) WriteStats(run string) error {
+	if pg.Config.StatsTable == "" {
+		return nil
+	}
+
+	if err := pg.createSchema(pg.Config.ProductionSchema); err != nil {
+		return err
+	}
+
+	tx, err := pg.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer rollbackIfTx(&tx)
+
+	statsTable := fmt.Sprintf(`"%s"."%s"`, pg.Config.ProductionSchema, pg.Config.StatsTable)
+	sql := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		time TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+		run VARCHAR NOT NULL,
+		table_name VARCHAR NOT NULL,
+		inserted BIGINT NOT NULL,
+		deleted BIGINT NOT NULL,
+		errors BIGINT NOT NULL,
+		bytes BIGINT NOT NULL,
+		duration_seconds DOUBLE PRECISION NOT NULL
+	)`, statsTable)
+	if _, err := tx.Exec(sql); err != nil {
+		return &SQLError{sql, err}
+	}
+
+	names := make([]string, 0, len(pg.stats.tables))
+	for name := range pg.stats.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sql = fmt.Sprintf(`INSERT INTO %s (run, table_name, inserted, deleted, errors, bytes, duration_seconds)
+		VALUES ($1, $2, $3, $4, $5, COALESCE(pg_total_relation_size(to_regclass($6)), 0), $7)`, statsTable)
+	for _, name := range names {
+		ts := pg.stats.tables[name]
+		inserted := atomic.LoadInt64(&ts.inserted)
+		deleted := atomic.LoadInt64(&ts.deleted)
+		errors := atomic.LoadInt64(&ts.errors)
+		if run != "import" && inserted == 0 && deleted == 0 && errors == 0 {
+			continue
+		}
+		spec := pg.Tables[name]
+		if _, err := tx.Exec(sql,
+			run, spec.FullName, inserted, deleted, errors,
+			fmt.Sprintf(`"%s"."%s"`, spec.Schema, spec.FullName),
+			pg.stats.duration.Seconds(),
+		); err != nil {
+			return &SQLError{sql, err}
+		}
+	}
+
+	err = tx.Commit()
+	tx = nil // set nil to prevent rollback
+	if err != nil {
+		return err
+	}
+	log.Printf("[info] wrote statistics to %s", statsTable)
+	return nil
+}
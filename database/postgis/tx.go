@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/omniscale/imposm3/log"
 )
@@ -26,15 +27,37 @@ type bulkTableTx struct {
 	InsertSQL  string
 	wg         *sync.WaitGroup
 	rows       chan []interface{}
+	// budget limits the number of buffered rows of all tables, if not nil
+	budget chan struct{}
+
+	waitMu   sync.Mutex
+	lastWait time.Time
 }
 
-func NewBulkTableTx(pg *PostGIS, spec *TableSpec) TableTx {
+// defaultBulkBufferSize is the number of rows buffered for each table
+// during bulk imports, if not configured.
+const defaultBulkBufferSize = 64
+
+// bulkWaitWarning is the duration an insert needs to wait for the database
+// before a warning is logged. Only one warning per table is logged in this
+// time.
+const bulkWaitWarning = time.Minute
+
+// NewBulkTableTx creates a TableTx that copies all rows into the table.
+// Insert blocks if the database is slower than the writers and if the
+// buffer of this table or the shared budget is full.
+func NewBulkTableTx(pg *PostGIS, spec *TableSpec, budget chan struct{}) TableTx {
+	bufferSize := pg.Config.BulkBufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultBulkBufferSize
+	}
 	tt := &bulkTableTx{
-		Pg:    pg,
-		Table: spec.FullName,
-		Spec:  spec,
-		wg:    &sync.WaitGroup{},
-		rows:  make(chan []interface{}, 64),
+		Pg:     pg,
+		Table:  spec.FullName,
+		Spec:   spec,
+		wg:     &sync.WaitGroup{},
+		rows:   make(chan []interface{}, bufferSize),
+		budget: budget,
 	}
 	tt.wg.Add(1)
 	go tt.loop()
@@ -68,10 +91,28 @@ func (tt *bulkTableTx) Begin(tx *sql.Tx) error {
 }
 
 func (tt *bulkTableTx) Insert(row []interface{}) error {
+	start := time.Now()
+	if tt.budget != nil {
+		tt.budget <- struct{}{}
+	}
 	tt.rows <- row
+	if wait := time.Since(start); wait > time.Second {
+		tt.waited(wait)
+	}
 	return nil
 }
 
+// waited logs that an insert was blocked, at most once per bulkWaitWarning.
+func (tt *bulkTableTx) waited(wait time.Duration) {
+	tt.waitMu.Lock()
+	defer tt.waitMu.Unlock()
+	if time.Since(tt.lastWait) < bulkWaitWarning {
+		return
+	}
+	tt.lastWait = time.Now()
+	log.Printf("[warn] bulk insert into %q waited %s for the database", tt.Table, wait.Truncate(time.Millisecond))
+}
+
 func (tt *bulkTableTx) loop() {
 	for row := range tt.rows {
 		_, err := tt.InsertStmt.Exec(row...)
@@ -80,6 +121,9 @@ func (tt *bulkTableTx) loop() {
 			// Abort the import as the whole transaction is lost anyway.
 			log.Fatalf("[fatal] bulk insert into %q: %s", tt.Table, &SQLError{tt.InsertSQL, err})
 		}
+		if tt.budget != nil {
+			<-tt.budget
+		}
 	}
 	tt.wg.Done()
 }
@@ -126,7 +170,7 @@ type syncTableTx struct {
 
 type tableSpec interface {
 	InsertSQL() string
-	DeleteSQL() string
+	DeleteSQL() (string, error)
 }
 
 func NewSynchronousTableTx(pg *PostGIS, tableName string, spec tableSpec) TableTx {
@@ -148,6 +192,12 @@ func (tt *syncTableTx) Begin(tx *sql.Tx) error {
 	}
 	tt.Tx = tx
 
+	// DeleteSQL fails for tables without id column
+	tt.DeleteSQL, err = tt.Spec.DeleteSQL()
+	if err != nil {
+		return err
+	}
+
 	tt.InsertSQL = tt.Spec.InsertSQL()
 
 	stmt, err := tt.Tx.Prepare(tt.InsertSQL)
@@ -156,7 +206,6 @@ func (tt *syncTableTx) Begin(tx *sql.Tx) error {
 	}
 	tt.InsertStmt = stmt
 
-	tt.DeleteSQL = tt.Spec.DeleteSQL()
 	stmt, err = tt.Tx.Prepare(tt.DeleteSQL)
 	if err != nil {
 		return &SQLError{tt.DeleteSQL, err}
@@ -0,0 +1,96 @@
+/*
+Package drop provides the drop sub command to remove all data created by imports.
+*/
+package drop
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/omniscale/imposm3/cache"
+	"github.com/omniscale/imposm3/config"
+	"github.com/omniscale/imposm3/database"
+	_ "github.com/omniscale/imposm3/database/postgis"
+	"github.com/omniscale/imposm3/log"
+	"github.com/omniscale/imposm3/mapping"
+	"github.com/omniscale/imposm3/update"
+)
+
+// Drop removes all tables of the mapping from the import and backup schemas
+// (and from the production schema with -production), the cache files and
+// the replication state and downloaded diff files.
+func Drop(dropOpts config.Drop) {
+	baseOpts := dropOpts.Base
+
+	tagmapping, err := mapping.FromFile(baseOpts.MappingFile)
+	if err != nil {
+		log.Fatal("[error] reading mapping file: ", err)
+	}
+
+	conf := database.Config{
+		ConnectionParams: baseOpts.Connection,
+		Srid:             baseOpts.Srid,
+		ImportSchema:     baseOpts.Schemas.Import,
+		ProductionSchema: baseOpts.Schemas.Production,
+		BackupSchema:     baseOpts.Schemas.Backup,
+		TablePrefix:      baseOpts.TablePrefix,
+		TableSuffix:      baseOpts.TableSuffix,
+	}
+	db, err := database.Open(conf, &tagmapping.Conf)
+	if err != nil {
+		log.Fatal("[error] opening database: ", err)
+	}
+	defer db.Close()
+
+	if db, ok := db.(database.Dropper); ok {
+		if err := db.Drop(dropOpts.Production); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		log.Fatal("database not dropable")
+	}
+
+	log.Printf("[info] removing cache %s", baseOpts.CacheDir)
+	if err := cache.NewOSMCache(baseOpts.CacheDir).Remove(); err != nil {
+		log.Fatal("unable to remove cache: ", err)
+	}
+	if err := cache.NewDiffCache(baseOpts.CacheDir).Remove(); err != nil {
+		log.Fatal("unable to remove diff cache: ", err)
+	}
+
+	log.Printf("[info] removing diff files and state from %s", baseOpts.DiffDir)
+	if err := removeDiffFiles(baseOpts.DiffDir); err != nil {
+		log.Fatal("unable to remove diff files: ", err)
+	}
+}
+
+// sequenceDir matches the directories of downloaded diff files
+// (e.g. 000/012/345.osc.gz).
+var sequenceDir = regexp.MustCompile(`^\d{3}$`)
+
+// removeDiffFiles removes last.state.txt and all downloaded diff files from
+// dir. Other files are left untouched, as dir can be shared with the cache.
+func removeDiffFiles(dir string) error {
+	err := os.Remove(filepath.Join(dir, update.LastStateFilename))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, fi := range files {
+		if fi.IsDir() && sequenceDir.MatchString(fi.Name()) {
+			if err := os.RemoveAll(filepath.Join(dir, fi.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
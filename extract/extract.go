@@ -0,0 +1,218 @@
+/*
+Package extract provides the extract sub command to write all elements of the
+cache that match the mapping as OSM PBF.
+*/
+package extract
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sort"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/cache"
+	"github.com/omniscale/imposm3/config"
+	"github.com/omniscale/imposm3/log"
+	"github.com/omniscale/imposm3/mapping"
+	"github.com/pkg/errors"
+)
+
+// Extract writes all nodes, ways and relations from the cache that match a
+// table of the mapping to extractOpts.Output. Member ways and nodes of
+// matched relations and the nodes of all written ways are included, so that
+// the extract is referentially complete. References to members that are
+// not part of the extract are removed from the relations.
+func Extract(extractOpts config.Extract) {
+	baseOpts := extractOpts.Base
+	if baseOpts.Quiet {
+		log.SetMinLevel(log.LInfo)
+	}
+
+	tagmapping, err := mapping.FromFile(baseOpts.MappingFile)
+	if err != nil {
+		log.Fatal("[fatal] Reading mapping file: ", err)
+	}
+
+	osmCache := cache.NewOSMCache(baseOpts.CacheDir)
+	if !osmCache.Exists() {
+		log.Fatal("[fatal] No cache found in ", baseOpts.CacheDir, ", run imposm import -read first")
+	}
+	if err := osmCache.Open(); err != nil {
+		log.Fatal("[fatal] Opening OSM cache: ", err)
+	}
+	defer osmCache.Close()
+
+	f, err := os.Create(extractOpts.Output)
+	if err != nil {
+		log.Fatal("[fatal] Creating extract: ", err)
+	}
+	w := bufio.NewWriter(f)
+
+	if err := extract(tagmapping, osmCache, w); err != nil {
+		f.Close()
+		os.Remove(extractOpts.Output)
+		osmCache.Close()
+		log.Fatal("[fatal] Writing extract: ", err)
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatal("[fatal] Writing extract: ", err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal("[fatal] Writing extract: ", err)
+	}
+}
+
+type idSet map[int64]struct{}
+
+func (s idSet) add(id int64) {
+	s[id] = struct{}{}
+}
+
+func (s idSet) has(id int64) bool {
+	_, ok := s[id]
+	return ok
+}
+
+func extract(m *mapping.Mapping, osmCache *cache.OSMCache, w io.Writer) error {
+	step := log.Step("Collecting matching elements")
+	var rels []osm.Relation
+	relIDs := make(idSet)
+	wayIDs := make(idSet)
+	nodeIDs := make(idSet)
+
+	for rel := range osmCache.Relations.Iter() {
+		if !matchRelation(m, rel) {
+			continue
+		}
+		rels = append(rels, *rel)
+		relIDs.add(rel.ID)
+		for _, member := range rel.Members {
+			switch member.Type {
+			case osm.WayMember:
+				wayIDs.add(member.ID)
+			case osm.NodeMember:
+				nodeIDs.add(member.ID)
+			}
+		}
+	}
+
+	for way := range osmCache.Ways.Iter() {
+		if !wayIDs.has(way.ID) {
+			if len(m.LineStringMatcher.MatchWay(way)) == 0 && len(m.PolygonMatcher.MatchWay(way)) == 0 {
+				continue
+			}
+			wayIDs.add(way.ID)
+		}
+		for _, ref := range way.Refs {
+			nodeIDs.add(ref)
+		}
+	}
+
+	for node := range osmCache.Nodes.Iter() {
+		if len(m.PointMatcher.MatchNode(node)) > 0 {
+			nodeIDs.add(node.ID)
+		}
+	}
+	step()
+
+	pw, err := newPBFWriter(w)
+	if err != nil {
+		return err
+	}
+
+	step = log.Step("Writing nodes")
+	ids := make([]int64, 0, len(nodeIDs))
+	for id := range nodeIDs {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	missingNodes := 0
+	nodes := make([]osm.Node, 0, blockSize)
+	for _, id := range ids {
+		node, err := osmCache.Nodes.GetNode(id)
+		if err == cache.NotFound {
+			node, err = osmCache.Coords.GetCoord(id)
+		}
+		if err == cache.NotFound {
+			missingNodes++
+			delete(nodeIDs, id)
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "fetching node %d", id)
+		}
+		nodes = append(nodes, *node)
+		if len(nodes) == blockSize {
+			if err := pw.WriteNodes(nodes); err != nil {
+				return err
+			}
+			nodes = nodes[:0]
+		}
+	}
+	if err := pw.WriteNodes(nodes); err != nil {
+		return err
+	}
+	if missingNodes > 0 {
+		log.Printf("[warn] %d referenced nodes are not cached, e.g. because they are outside of the imported area", missingNodes)
+	}
+	step()
+
+	step = log.Step("Writing ways")
+	written := make(idSet, len(wayIDs))
+	ways := make([]osm.Way, 0, blockSize)
+	for way := range osmCache.Ways.Iter() {
+		if !wayIDs.has(way.ID) {
+			continue
+		}
+		written.add(way.ID)
+		ways = append(ways, *way)
+		if len(ways) == blockSize {
+			if err := pw.WriteWays(ways); err != nil {
+				return err
+			}
+			ways = ways[:0]
+		}
+	}
+	if err := pw.WriteWays(ways); err != nil {
+		return err
+	}
+	step()
+
+	step = log.Step("Writing relations")
+	for i := range rels {
+		members := rels[i].Members[:0]
+		for _, member := range rels[i].Members {
+			switch member.Type {
+			case osm.NodeMember:
+				if !nodeIDs.has(member.ID) {
+					continue
+				}
+			case osm.WayMember:
+				if !written.has(member.ID) {
+					continue
+				}
+			case osm.RelationMember:
+				if !relIDs.has(member.ID) {
+					continue
+				}
+			}
+			members = append(members, member)
+		}
+		rels[i].Members = members
+	}
+	if err := pw.WriteRelations(rels); err != nil {
+		return err
+	}
+	step()
+
+	log.Printf("[info] Extracted %d nodes, %d ways and %d relations", len(nodeIDs), len(written), len(rels))
+	return nil
+}
+
+func matchRelation(m *mapping.Mapping, rel *osm.Relation) bool {
+	return len(m.PolygonMatcher.MatchRelation(rel)) > 0 ||
+		len(m.RelationMatcher.MatchRelation(rel)) > 0 ||
+		len(m.RelationMemberMatcher.MatchRelation(rel)) > 0
+}
@@ -0,0 +1,273 @@
+package extract
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/gogo/protobuf/proto"
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3"
+	"github.com/pkg/errors"
+)
+
+// blockSize is the maximum number of elements of a single PBF block, as
+// recommended by the PBF specification.
+const blockSize = 8000
+
+// protobuf wire types
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+// pbfWriter writes nodes, ways and relations as OSM PBF. Elements need to
+// be written ordered by type (nodes, ways, relations) and ID.
+type pbfWriter struct {
+	w io.Writer
+}
+
+func newPBFWriter(w io.Writer) (*pbfWriter, error) {
+	pw := &pbfWriter{w: w}
+	header := proto.NewBuffer(nil)
+	for _, feature := range []string{"OsmSchema-V0.6", "DenseNodes"} {
+		encodeString(header, 4, feature) // required_features
+	}
+	encodeString(header, 16, "imposm "+imposm3.Version) // writingprogram
+	if err := pw.writeBlob("OSMHeader", header.Bytes()); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+// WriteNodes writes nodes as DenseNodes, in blocks of blockSize nodes.
+func (pw *pbfWriter) WriteNodes(nodes []osm.Node) error {
+	for len(nodes) > 0 {
+		n := min(len(nodes), blockSize)
+		st := newStringTable()
+		var ids, lats, lons, keysVals []uint64
+		var lastID, lastLat, lastLon int64
+		hasTags := false
+		for _, nd := range nodes[:n] {
+			lat, lon := coord(nd.Lat), coord(nd.Long)
+			ids = append(ids, zigzag(nd.ID-lastID))
+			lats = append(lats, zigzag(lat-lastLat))
+			lons = append(lons, zigzag(lon-lastLon))
+			lastID, lastLat, lastLon = nd.ID, lat, lon
+			for _, k := range sortedKeys(nd.Tags) {
+				keysVals = append(keysVals, uint64(st.index(k)), uint64(st.index(nd.Tags[k])))
+				hasTags = true
+			}
+			keysVals = append(keysVals, 0)
+		}
+		dense := proto.NewBuffer(nil)
+		encodePacked(dense, 1, ids)
+		encodePacked(dense, 8, lats)
+		encodePacked(dense, 9, lons)
+		if hasTags {
+			encodePacked(dense, 10, keysVals)
+		}
+		group := proto.NewBuffer(nil)
+		encodeBytes(group, 2, dense.Bytes())
+		if err := pw.writeBlock(st, group.Bytes()); err != nil {
+			return err
+		}
+		nodes = nodes[n:]
+	}
+	return nil
+}
+
+// WriteWays writes ways in blocks of blockSize ways.
+func (pw *pbfWriter) WriteWays(ways []osm.Way) error {
+	for len(ways) > 0 {
+		n := min(len(ways), blockSize)
+		st := newStringTable()
+		group := proto.NewBuffer(nil)
+		for _, w := range ways[:n] {
+			way := proto.NewBuffer(nil)
+			encodeVarint(way, 1, uint64(w.ID))
+			encodeTags(way, st, w.Tags)
+			refs := make([]uint64, len(w.Refs))
+			var last int64
+			for i, ref := range w.Refs {
+				refs[i] = zigzag(ref - last)
+				last = ref
+			}
+			encodePacked(way, 8, refs)
+			encodeBytes(group, 3, way.Bytes())
+		}
+		if err := pw.writeBlock(st, group.Bytes()); err != nil {
+			return err
+		}
+		ways = ways[n:]
+	}
+	return nil
+}
+
+// WriteRelations writes relations in blocks of blockSize relations.
+func (pw *pbfWriter) WriteRelations(rels []osm.Relation) error {
+	for len(rels) > 0 {
+		n := min(len(rels), blockSize)
+		st := newStringTable()
+		group := proto.NewBuffer(nil)
+		for _, r := range rels[:n] {
+			rel := proto.NewBuffer(nil)
+			encodeVarint(rel, 1, uint64(r.ID))
+			encodeTags(rel, st, r.Tags)
+			roles := make([]uint64, len(r.Members))
+			memids := make([]uint64, len(r.Members))
+			types := make([]uint64, len(r.Members))
+			var last int64
+			for i, m := range r.Members {
+				roles[i] = uint64(st.index(m.Role))
+				memids[i] = zigzag(m.ID - last)
+				last = m.ID
+				types[i] = uint64(m.Type)
+			}
+			encodePacked(rel, 8, roles)
+			encodePacked(rel, 9, memids)
+			encodePacked(rel, 10, types)
+			encodeBytes(group, 4, rel.Bytes())
+		}
+		if err := pw.writeBlock(st, group.Bytes()); err != nil {
+			return err
+		}
+		rels = rels[n:]
+	}
+	return nil
+}
+
+// writeBlock writes a PrimitiveBlock with a single PrimitiveGroup. The
+// block uses the default granularity (100 nanodegrees) and offsets.
+func (pw *pbfWriter) writeBlock(st *stringTable, group []byte) error {
+	table := proto.NewBuffer(nil)
+	for _, s := range st.strings {
+		encodeString(table, 1, s)
+	}
+	block := proto.NewBuffer(nil)
+	encodeBytes(block, 1, table.Bytes())
+	encodeBytes(block, 2, group)
+	return pw.writeBlob("OSMData", block.Bytes())
+}
+
+// writeBlob writes data as zlib compressed Blob with a BlobHeader of
+// blobType.
+func (pw *pbfWriter) writeBlob(blobType string, data []byte) error {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	if _, err := zw.Write(data); err != nil {
+		return errors.Wrap(err, "compressing blob")
+	}
+	if err := zw.Close(); err != nil {
+		return errors.Wrap(err, "compressing blob")
+	}
+	blob := proto.NewBuffer(nil)
+	encodeVarint(blob, 2, uint64(len(data))) // raw_size
+	encodeBytes(blob, 3, compressed.Bytes()) // zlib_data
+
+	header := proto.NewBuffer(nil)
+	encodeString(header, 1, blobType)
+	encodeVarint(header, 3, uint64(len(blob.Bytes()))) // datasize
+
+	if err := binary.Write(pw.w, binary.BigEndian, int32(len(header.Bytes()))); err != nil {
+		return errors.Wrap(err, "writing blob header size")
+	}
+	if _, err := pw.w.Write(header.Bytes()); err != nil {
+		return errors.Wrap(err, "writing blob header")
+	}
+	if _, err := pw.w.Write(blob.Bytes()); err != nil {
+		return errors.Wrap(err, "writing blob")
+	}
+	return nil
+}
+
+// stringTable collects all strings of a block. Index 0 is reserved as
+// delimiter.
+type stringTable struct {
+	strings []string
+	indices map[string]int
+}
+
+func newStringTable() *stringTable {
+	return &stringTable{strings: []string{""}, indices: map[string]int{"": 0}}
+}
+
+func (st *stringTable) index(s string) int {
+	if idx, ok := st.indices[s]; ok {
+		return idx
+	}
+	idx := len(st.strings)
+	st.strings = append(st.strings, s)
+	st.indices[s] = idx
+	return idx
+}
+
+func encodeTags(b *proto.Buffer, st *stringTable, tags osm.Tags) {
+	keys := sortedKeys(tags)
+	if len(keys) == 0 {
+		return
+	}
+	ks := make([]uint64, len(keys))
+	vs := make([]uint64, len(keys))
+	for i, k := range keys {
+		ks[i] = uint64(st.index(k))
+		vs[i] = uint64(st.index(tags[k]))
+	}
+	encodePacked(b, 2, ks)
+	encodePacked(b, 3, vs)
+}
+
+func sortedKeys(tags osm.Tags) []string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func encodeVarint(b *proto.Buffer, field int, v uint64) {
+	b.EncodeVarint(uint64(field<<3 | wireVarint))
+	b.EncodeVarint(v)
+}
+
+func encodeBytes(b *proto.Buffer, field int, v []byte) {
+	b.EncodeVarint(uint64(field<<3 | wireBytes))
+	b.EncodeRawBytes(v)
+}
+
+func encodeString(b *proto.Buffer, field int, v string) {
+	encodeBytes(b, field, []byte(v))
+}
+
+// encodePacked encodes values as packed repeated varints. Signed values
+// need to be zigzag encoded by the caller.
+func encodePacked(b *proto.Buffer, field int, values []uint64) {
+	if len(values) == 0 {
+		return
+	}
+	packed := proto.NewBuffer(nil)
+	for _, v := range values {
+		packed.EncodeVarint(v)
+	}
+	encodeBytes(b, field, packed.Bytes())
+}
+
+func zigzag(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
+
+// coord returns the coordinate in units of the default granularity.
+func coord(v float64) int64 {
+	return int64(math.Round(v * 1e7))
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
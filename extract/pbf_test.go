@@ -0,0 +1,90 @@
+package extract
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/go-osm/parser/pbf"
+)
+
+func TestPBFWriterRoundTrip(t *testing.T) {
+	nodes := []osm.Node{
+		{Element: osm.Element{ID: 1}, Long: 8.5, Lat: 53.1},
+		{Element: osm.Element{ID: 2, Tags: osm.Tags{"amenity": "cafe", "name": "Café"}}, Long: -8.25, Lat: -53.0000001},
+		{Element: osm.Element{ID: 5}, Long: 8.5001, Lat: 53.1002},
+	}
+	ways := []osm.Way{
+		{Element: osm.Element{ID: 10, Tags: osm.Tags{"highway": "primary"}}, Refs: []int64{1, 5, 2}},
+		{Element: osm.Element{ID: 11}, Refs: []int64{5, 1}},
+	}
+	rels := []osm.Relation{
+		{Element: osm.Element{ID: 20, Tags: osm.Tags{"type": "route"}}, Members: []osm.Member{
+			{ID: 10, Type: osm.WayMember, Role: "forward"},
+			{ID: 2, Type: osm.NodeMember, Role: "stop"},
+			{ID: 11, Type: osm.WayMember},
+		}},
+	}
+
+	var buf bytes.Buffer
+	pw, err := newPBFWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pw.WriteNodes(nodes); err != nil {
+		t.Fatal(err)
+	}
+	if err := pw.WriteWays(ways); err != nil {
+		t.Fatal(err)
+	}
+	if err := pw.WriteRelations(rels); err != nil {
+		t.Fatal(err)
+	}
+
+	nodesc := make(chan []osm.Node, 10)
+	waysc := make(chan []osm.Way, 10)
+	relsc := make(chan []osm.Relation, 10)
+	p := pbf.New(&buf, pbf.Config{Nodes: nodesc, Ways: waysc, Relations: relsc, Concurrency: 1})
+	if err := p.Parse(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var gotNodes []osm.Node
+	for nds := range nodesc {
+		gotNodes = append(gotNodes, nds...)
+	}
+	if len(gotNodes) != len(nodes) {
+		t.Fatalf("expected %d nodes, got %v", len(nodes), gotNodes)
+	}
+	for i, nd := range gotNodes {
+		want := nodes[i]
+		if nd.ID != want.ID || !reflect.DeepEqual(nd.Tags, want.Tags) ||
+			coord(nd.Long) != coord(want.Long) || coord(nd.Lat) != coord(want.Lat) {
+			t.Errorf("expected node %v, got %v", want, nd)
+		}
+	}
+
+	var gotWays []osm.Way
+	for ws := range waysc {
+		gotWays = append(gotWays, ws...)
+	}
+	if len(gotWays) != len(ways) {
+		t.Fatalf("expected %d ways, got %v", len(ways), gotWays)
+	}
+	for i, w := range gotWays {
+		want := ways[i]
+		if w.ID != want.ID || !reflect.DeepEqual(w.Refs, want.Refs) || len(w.Tags) != len(want.Tags) {
+			t.Errorf("expected way %v, got %v", want, w)
+		}
+	}
+
+	var gotRels []osm.Relation
+	for rs := range relsc {
+		gotRels = append(gotRels, rs...)
+	}
+	if len(gotRels) != 1 || gotRels[0].ID != 20 || !reflect.DeepEqual(gotRels[0].Members, rels[0].Members) {
+		t.Errorf("expected relation %v, got %v", rels[0], gotRels)
+	}
+}
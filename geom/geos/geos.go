@@ -105,6 +105,11 @@ func (g *Geos) SetHandleSrid(srid int) {
 	g.srid = srid
 }
 
+// Srid returns the SRID of geom, or 0 if it is not set.
+func (g *Geos) Srid(geom *Geom) int {
+	return int(C.GEOSGetSRID_r(g.v, geom.v))
+}
+
 func (g *Geos) NumGeoms(geom *Geom) int32 {
 	count := int32(C.GEOSGetNumGeometries_r(g.v, geom.v))
 	return count
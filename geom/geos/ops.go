@@ -50,6 +50,23 @@ func (g *Geos) SimplifyPreserveTopology(geom *Geom, tolerance float64) *Geom {
 	return &Geom{simplified}
 }
 
+func (g *Geos) Centroid(geom *Geom) *Geom {
+	centroid := C.GEOSGetCentroid_r(g.v, geom.v)
+	if centroid == nil {
+		return nil
+	}
+	return &Geom{centroid}
+}
+
+// PointOnSurface returns a point that is guaranteed to be inside of geom.
+func (g *Geos) PointOnSurface(geom *Geom) *Geom {
+	point := C.GEOSPointOnSurface_r(g.v, geom.v)
+	if point == nil {
+		return nil
+	}
+	return &Geom{point}
+}
+
 // UnionPolygons tries to merge polygons.
 // Returns a single (Multi)Polygon.
 // Destroys polygons and returns new allocated (Multi)Polygon as necessary.
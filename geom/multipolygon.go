@@ -25,6 +25,99 @@ func PrepareRelation(rel *osm.Relation, srid int, maxRingGap float64) (PreparedR
 	return PreparedRelation{rings, rel, srid}, nil
 }
 
+// RingBuilder builds the rings of a relation incrementally, as an
+// alternative to PrepareRelation for relations with too many members to
+// keep all member ways in memory. Each way is merged with the open rings
+// and a ring is converted to a polygon as soon as it is closed. Only the
+// nodes of rings that are still open are kept.
+type RingBuilder struct {
+	g          *geos.Geos
+	maxRingGap float64
+	tolerance  float64
+	merger     *ringMerger
+	complete   []*ring
+}
+
+func NewRingBuilder(g *geos.Geos, maxRingGap float64) *RingBuilder {
+	return &RingBuilder{
+		g:          g,
+		maxRingGap: maxRingGap,
+		merger:     newRingMerger(),
+	}
+}
+
+// SetSimplifyTolerance enables simplification (preserving the topology)
+// of each ring as soon as it is closed.
+func (rb *RingBuilder) SetSimplifyTolerance(tolerance float64) {
+	rb.tolerance = tolerance
+}
+
+// AddWay adds a member way with its nodes. The nodes are copied and way
+// is not referenced afterwards.
+func (rb *RingBuilder) AddWay(way *osm.Way) error {
+	r := newRing(way)
+	// only the ID is required to set the role of the member
+	r.ways = []*osm.Way{{Element: osm.Element{ID: way.ID}}}
+	if r.isClosed() {
+		return rb.closeRing(r)
+	}
+	if merged := rb.merger.add(r); merged != nil && merged.isClosed() {
+		rb.merger.remove(merged)
+		return rb.closeRing(merged)
+	}
+	return nil
+}
+
+func (rb *RingBuilder) closeRing(r *ring) error {
+	geom, err := Polygon(rb.g, r.nodes)
+	if err != nil {
+		return err
+	}
+	if rb.tolerance > 0 {
+		if simplified := rb.g.SimplifyPreserveTopology(geom, rb.tolerance); simplified != nil {
+			rb.g.Destroy(geom)
+			geom = simplified
+		}
+	}
+	r.geom = geom
+	r.area = geom.Area()
+	r.refs = nil
+	r.nodes = nil
+	rb.complete = append(rb.complete, r)
+	return nil
+}
+
+// Prepare closes the remaining rings like PrepareRelation and returns
+// the PreparedRelation of rel. rel.Members are only used to set the
+// outer/inner roles.
+func (rb *RingBuilder) Prepare(rel *osm.Relation, srid int) (PreparedRelation, error) {
+	for _, r := range rb.merger.rings() {
+		if !r.isClosed() && !r.tryClose(rb.maxRingGap) {
+			continue
+		}
+		if err := rb.closeRing(r); err != nil {
+			rb.Destroy()
+			return PreparedRelation{}, err
+		}
+	}
+	rb.merger = newRingMerger()
+
+	if len(rb.complete) == 0 {
+		return PreparedRelation{}, ErrorNoRing
+	}
+	rings := rb.complete
+	rb.complete = nil
+	sort.Sort(sortableRingsDesc(rings))
+	return PreparedRelation{rings, rel, srid}, nil
+}
+
+// Destroy frees the geometries of all closed rings. Call Destroy if
+// Prepare is not called, e.g. after an error of AddWay.
+func (rb *RingBuilder) Destroy() {
+	destroyRings(rb.g, rb.complete)
+	rb.complete = nil
+}
+
 // Build creates the (multi)polygon Geometry of the Relation.
 func (prep *PreparedRelation) Build() (Geometry, error) {
 	g := geos.NewGeos()
@@ -659,3 +659,121 @@ func TestClosedAndOpenRing(t *testing.T) {
 		t.Fatal("geometry not valid", g.AsWkt(geom.Geom))
 	}
 }
+
+func TestRingBuilder(t *testing.T) {
+	w1 := makeWay(1, osm.Tags{}, []coord{
+		{1, 0, 0},
+		{2, 10, 0},
+		{3, 10, 10},
+	})
+	w2 := makeWay(2, osm.Tags{}, []coord{
+		{5, 2, 2},
+		{6, 8, 2},
+		{7, 8, 8},
+		{8, 2, 8},
+		{5, 2, 2},
+	})
+	w3 := makeWay(3, osm.Tags{}, []coord{
+		{3, 10, 10},
+		{4, 0, 10},
+		{1, 0, 0},
+	})
+
+	rel := osm.Relation{
+		Element: osm.Element{ID: 1, Tags: osm.Tags{}}}
+	rel.Members = []osm.Member{
+		{ID: 1, Type: osm.WayMember},
+		{ID: 2, Type: osm.WayMember},
+		{ID: 3, Type: osm.WayMember},
+	}
+
+	g := geos.NewGeos()
+	defer g.Finish()
+
+	rb := NewRingBuilder(g, 0.1)
+	for _, w := range []*osm.Way{&w1, &w2, &w3} {
+		if err := rb.AddWay(w); err != nil {
+			t.Fatal(err)
+		}
+		// ways are not referenced by the builder
+		w.Nodes = nil
+	}
+	prep, err := rb.Prepare(&rel, 3857)
+	if err != nil {
+		t.Fatal(err)
+	}
+	geom, err := prep.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !g.IsValid(geom.Geom) {
+		t.Fatal("geometry not valid", g.AsWkt(geom.Geom))
+	}
+	if area := geom.Geom.Area(); area != 100-36 {
+		t.Fatal("area invalid", area)
+	}
+	for i, role := range []string{"outer", "inner", "outer"} {
+		if rel.Members[i].Role != role {
+			t.Error("unexpected role", rel.Members[i])
+		}
+	}
+}
+
+func TestRingBuilderSimplify(t *testing.T) {
+	w1 := makeWay(1, osm.Tags{}, []coord{
+		{1, 0, 0},
+		{2, 5, 0.01},
+		{3, 10, 0},
+		{4, 10, 10},
+		{5, 0, 10},
+		{1, 0, 0},
+	})
+
+	rel := osm.Relation{
+		Element: osm.Element{ID: 1, Tags: osm.Tags{}}}
+	rel.Members = []osm.Member{
+		{ID: 1, Type: osm.WayMember},
+	}
+
+	g := geos.NewGeos()
+	defer g.Finish()
+
+	rb := NewRingBuilder(g, 0.1)
+	rb.SetSimplifyTolerance(1)
+	if err := rb.AddWay(&w1); err != nil {
+		t.Fatal(err)
+	}
+	prep, err := rb.Prepare(&rel, 3857)
+	if err != nil {
+		t.Fatal(err)
+	}
+	geom, err := prep.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if area := geom.Geom.Area(); area != 100 {
+		t.Fatal("area invalid", area)
+	}
+	if n := g.NumCoordinates(geom.Geom); n != 5 {
+		t.Fatal("expected simplified polygon", g.AsWkt(geom.Geom))
+	}
+}
+
+func TestRingBuilderNoRing(t *testing.T) {
+	w1 := makeWay(1, osm.Tags{}, []coord{
+		{1, 0, 0},
+		{2, 10, 0},
+		{3, 10, 10},
+	})
+	g := geos.NewGeos()
+	defer g.Finish()
+
+	rb := NewRingBuilder(g, 0.1)
+	if err := rb.AddWay(&w1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rb.Prepare(&osm.Relation{}, 3857); err != ErrorNoRing {
+		t.Fatal("expected ErrorNoRing", err)
+	}
+}
@@ -64,72 +64,105 @@ func reverseNodes(nodes []osm.Node) {
 }
 
 func mergeRings(rings []*ring) []*ring {
-	endpoints := make(map[int64]*ring)
-
+	m := newRingMerger()
 	for _, ring := range rings {
-		if len(ring.refs) < 2 {
-			continue
-		}
-		left := ring.refs[0]
-		right := ring.refs[len(ring.refs)-1]
+		m.add(ring)
+	}
+	return m.rings()
+}
 
-		if origRing, ok := endpoints[left]; ok {
-			// left node connects to..
-			delete(endpoints, left)
-			if left == origRing.refs[len(origRing.refs)-1] {
-				// .. right end
-				origRing.refs = append(origRing.refs, ring.refs[1:]...)
-				origRing.nodes = append(origRing.nodes, ring.nodes[1:]...)
-			} else {
-				// .. left end, reverse ring
-				reverseRefs(origRing.refs)
-				origRing.refs = append(origRing.refs, ring.refs[1:]...)
-				reverseNodes(origRing.nodes)
-				origRing.nodes = append(origRing.nodes, ring.nodes[1:]...)
-			}
-			origRing.ways = append(origRing.ways, ring.ways...)
-			if rightRing, ok := endpoints[right]; ok && rightRing != origRing {
-				// right node connects to another ring, close ring
-				delete(endpoints, right)
-				if right == rightRing.refs[0] {
-					origRing.refs = append(origRing.refs, rightRing.refs[1:]...)
-					origRing.nodes = append(origRing.nodes, rightRing.nodes[1:]...)
-				} else {
-					reverseRefs(rightRing.refs)
-					origRing.refs = append(origRing.refs[:len(origRing.refs)-1], rightRing.refs...)
-					reverseNodes(rightRing.nodes)
-					origRing.nodes = append(origRing.nodes[:len(origRing.nodes)-1], rightRing.nodes...)
-				}
-				origRing.ways = append(origRing.ways, rightRing.ways...)
-				right := origRing.refs[len(origRing.refs)-1]
-				endpoints[right] = origRing
-			} else {
-				endpoints[right] = origRing
-			}
-		} else if origRing, ok := endpoints[right]; ok {
-			// right node connects to..
+// ringMerger merges rings that share an end node, one ring at a time.
+type ringMerger struct {
+	endpoints map[int64]*ring
+}
+
+func newRingMerger() *ringMerger {
+	return &ringMerger{endpoints: make(map[int64]*ring)}
+}
+
+// add merges ring with the rings it connects to and returns the merged
+// ring, or ring itself if it is not connected (yet). It returns nil for
+// rings with less than two refs.
+func (m *ringMerger) add(ring *ring) *ring {
+	endpoints := m.endpoints
+	if len(ring.refs) < 2 {
+		return nil
+	}
+	left := ring.refs[0]
+	right := ring.refs[len(ring.refs)-1]
+
+	if origRing, ok := endpoints[left]; ok {
+		// left node connects to..
+		delete(endpoints, left)
+		if left == origRing.refs[len(origRing.refs)-1] {
+			// .. right end
+			origRing.refs = append(origRing.refs, ring.refs[1:]...)
+			origRing.nodes = append(origRing.nodes, ring.nodes[1:]...)
+		} else {
+			// .. left end, reverse ring
+			reverseRefs(origRing.refs)
+			origRing.refs = append(origRing.refs, ring.refs[1:]...)
+			reverseNodes(origRing.nodes)
+			origRing.nodes = append(origRing.nodes, ring.nodes[1:]...)
+		}
+		origRing.ways = append(origRing.ways, ring.ways...)
+		if rightRing, ok := endpoints[right]; ok && rightRing != origRing {
+			// right node connects to another ring, close ring
 			delete(endpoints, right)
-			if right == origRing.refs[0] {
-				// .. left end
-				origRing.refs = append(ring.refs[:len(ring.refs)-1], origRing.refs...)
-				origRing.nodes = append(ring.nodes[:len(ring.nodes)-1], origRing.nodes...)
+			if right == rightRing.refs[0] {
+				origRing.refs = append(origRing.refs, rightRing.refs[1:]...)
+				origRing.nodes = append(origRing.nodes, rightRing.nodes[1:]...)
 			} else {
-				// .. right end, reverse ring
-				reverseRefs(ring.refs)
-				origRing.refs = append(origRing.refs[:len(origRing.refs)-1], ring.refs...)
-				reverseNodes(ring.nodes)
-				origRing.nodes = append(origRing.nodes[:len(origRing.nodes)-1], ring.nodes...)
+				reverseRefs(rightRing.refs)
+				origRing.refs = append(origRing.refs[:len(origRing.refs)-1], rightRing.refs...)
+				reverseNodes(rightRing.nodes)
+				origRing.nodes = append(origRing.nodes[:len(origRing.nodes)-1], rightRing.nodes...)
 			}
-			origRing.ways = append(origRing.ways, ring.ways...)
-			endpoints[left] = origRing
+			origRing.ways = append(origRing.ways, rightRing.ways...)
+			right := origRing.refs[len(origRing.refs)-1]
+			endpoints[right] = origRing
+		} else {
+			endpoints[right] = origRing
+		}
+		return origRing
+	} else if origRing, ok := endpoints[right]; ok {
+		// right node connects to..
+		delete(endpoints, right)
+		if right == origRing.refs[0] {
+			// .. left end
+			origRing.refs = append(ring.refs[:len(ring.refs)-1], origRing.refs...)
+			origRing.nodes = append(ring.nodes[:len(ring.nodes)-1], origRing.nodes...)
 		} else {
-			// ring is not connected (yet)
-			endpoints[left] = ring
-			endpoints[right] = ring
+			// .. right end, reverse ring
+			reverseRefs(ring.refs)
+			origRing.refs = append(origRing.refs[:len(origRing.refs)-1], ring.refs...)
+			reverseNodes(ring.nodes)
+			origRing.nodes = append(origRing.nodes[:len(origRing.nodes)-1], ring.nodes...)
 		}
+		origRing.ways = append(origRing.ways, ring.ways...)
+		endpoints[left] = origRing
+		return origRing
+	} else {
+		// ring is not connected (yet)
+		endpoints[left] = ring
+		endpoints[right] = ring
+		return ring
 	}
+}
+
+// remove removes r from the merger, e.g. after r was closed.
+func (m *ringMerger) remove(r *ring) {
+	for _, ref := range []int64{r.refs[0], r.refs[len(r.refs)-1]} {
+		if m.endpoints[ref] == r {
+			delete(m.endpoints, ref)
+		}
+	}
+}
+
+// rings returns all merged and unconnected rings.
+func (m *ringMerger) rings() []*ring {
 	uniqueRings := make(map[*ring]bool)
-	for _, ring := range endpoints {
+	for _, ring := range m.endpoints {
 		uniqueRings[ring] = true
 	}
 	result := make([]*ring, 0, len(uniqueRings))
@@ -247,3 +247,56 @@ func permutationNext(data sort.Interface) bool {
 	}
 	return true
 }
+
+func TestRingMergerIncremental(t *testing.T) {
+	way := func(id int64, refs ...int64) *ring {
+		w := osm.Way{}
+		w.ID = id
+		w.Refs = refs
+		w.Nodes = make([]osm.Node, len(refs))
+		return newRing(&w)
+	}
+
+	m := newRingMerger()
+	if r := m.add(way(1, 1, 2, 3)); r == nil || r.isClosed() {
+		t.Fatal("unexpected ring", r)
+	}
+	if r := m.add(way(2, 10, 11, 12)); r == nil || r.isClosed() {
+		t.Fatal("unexpected ring", r)
+	}
+	if r := m.add(way(3, 3, 4, 5)); r == nil || r.isClosed() || len(r.ways) != 2 {
+		t.Fatal("unexpected ring", r)
+	}
+	if r := m.add(way(4)); r != nil {
+		t.Fatal("expected nil for way without refs", r)
+	}
+	if len(m.rings()) != 2 {
+		t.Fatal("expected two open rings", m.rings())
+	}
+
+	// reversed way closes first ring
+	r := m.add(way(5, 1, 6, 5))
+	if r == nil || !r.isClosed() || len(r.ways) != 3 {
+		t.Fatal("expected closed ring", r)
+	}
+	expected := []int64{5, 4, 3, 2, 1, 6, 5}
+	if len(r.refs) != len(expected) {
+		t.Fatalf("%v != %v", r.refs, expected)
+	}
+	for i, ref := range r.refs {
+		if ref != expected[i] {
+			t.Fatalf("%v != %v", r.refs, expected)
+		}
+	}
+
+	m.remove(r)
+	rings := m.rings()
+	if len(rings) != 1 || rings[0].ways[0].ID != 2 {
+		t.Fatal("expected only open ring of way 2", rings)
+	}
+
+	// closed ring was removed and does not connect to new ways
+	if r := m.add(way(6, 5, 7, 8)); r == nil || len(r.ways) != 1 {
+		t.Fatal("unexpected ring", r)
+	}
+}
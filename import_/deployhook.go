@@ -0,0 +1,87 @@
+package import_
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/omniscale/imposm3/config"
+	"github.com/omniscale/imposm3/database"
+	"github.com/omniscale/imposm3/log"
+	"github.com/pkg/errors"
+)
+
+// deployEvent is sent as JSON to the URL of the deploy hook.
+type deployEvent struct {
+	Action string   `json:"action"`
+	Schema string   `json:"schema"`
+	Tables []string `json:"tables"`
+}
+
+// runDeployHook calls the configured deploy hook with all tables that db
+// moved into schema. Errors are only logged, as the deploy itself was
+// successful.
+func runDeployHook(hook config.DeployHook, action, schema string, db database.DB) {
+	if hook.URL == "" && len(hook.Command) == 0 {
+		return
+	}
+	reporter, ok := db.(database.DeployReporter)
+	if !ok {
+		log.Println("[warn] database does not report deployed tables, skipping deploy hook")
+		return
+	}
+	ev := deployEvent{
+		Action: action,
+		Schema: schema,
+		Tables: reporter.DeployedTables(),
+	}
+	if ev.Tables == nil {
+		ev.Tables = []string{}
+	}
+	sort.Strings(ev.Tables)
+
+	if hook.URL != "" {
+		if err := postDeployEvent(hook.URL, ev); err != nil {
+			log.Printf("[error] Calling deploy hook %s: %s", hook.URL, err)
+		}
+	}
+	if len(hook.Command) > 0 {
+		if err := execDeployEvent(hook.Command, ev); err != nil {
+			log.Printf("[error] Running deploy hook %s: %s", hook.Command[0], err)
+		}
+	}
+}
+
+func postDeployEvent(url string, ev deployEvent) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errors.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+func execDeployEvent(command []string, ev deployEvent) error {
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Env = append(os.Environ(),
+		"IMPOSM_DEPLOY_ACTION="+ev.Action,
+		"IMPOSM_DEPLOY_SCHEMA="+ev.Schema,
+		"IMPOSM_DEPLOY_TABLES="+strings.Join(ev.Tables, " "),
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -6,11 +6,15 @@ package import_
 import (
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/omniscale/go-osm/state"
+	"github.com/omniscale/imposm3/alert"
 	"github.com/omniscale/imposm3/cache"
 	"github.com/omniscale/imposm3/config"
 	"github.com/omniscale/imposm3/database"
+	_ "github.com/omniscale/imposm3/database/csv"
+	_ "github.com/omniscale/imposm3/database/ndjson"
 	_ "github.com/omniscale/imposm3/database/postgis"
 	"github.com/omniscale/imposm3/geom/limit"
 	"github.com/omniscale/imposm3/log"
@@ -21,9 +25,22 @@ import (
 	"github.com/omniscale/imposm3/writer"
 )
 
+// target is a mapping that is imported into a database. Imports write
+// the same cache into all targets.
+type target struct {
+	mappingFile string
+	mapping     *mapping.Mapping
+	connection  string
+	schemas     config.Schemas
+	db          database.DB
+}
+
 func Import(importOpts config.Import) {
 	baseOpts := importOpts.Base
 
+	alert.SendOnFatal(baseOpts.Alerts)
+	summary := newSummaryWriter(importOpts.Summary, importOpts)
+
 	if (importOpts.Write || importOpts.Read != "") && (importOpts.RevertDeploy || importOpts.RemoveBackup) {
 		log.Fatal("-revertdeploy and -removebackup not compatible with -read/-write")
 	}
@@ -52,27 +69,78 @@ func Import(importOpts config.Import) {
 		log.Fatal("[error] reading mapping file: ", err)
 	}
 
-	var db database.DB
-
-	if importOpts.Write || importOpts.DeployProduction || importOpts.RevertDeploy || importOpts.RemoveBackup || importOpts.Optimize {
-		if baseOpts.Connection == "" {
-			log.Fatal("[error] missing connection option in configuration")
+	targets := []*target{{
+		mappingFile: baseOpts.MappingFile,
+		mapping:     tagmapping,
+		connection:  baseOpts.Connection,
+		schemas:     baseOpts.Schemas,
+	}}
+	for _, t := range baseOpts.Targets {
+		m, err := mapping.FromFile(t.Mapping)
+		if err != nil {
+			log.Fatalf("[error] reading mapping file %s: %s", t.Mapping, err)
 		}
-		conf := database.Config{
-			ConnectionParams: baseOpts.Connection,
-			Srid:             baseOpts.Srid,
-			ImportSchema:     baseOpts.Schemas.Import,
-			ProductionSchema: baseOpts.Schemas.Production,
-			BackupSchema:     baseOpts.Schemas.Backup,
+		schemas := baseOpts.Schemas
+		if t.Schemas.Import != "" {
+			schemas.Import = t.Schemas.Import
 		}
-		db, err = database.Open(conf, &tagmapping.Conf)
-		if err != nil {
-			log.Fatal("[error] opening database: ", err)
+		if t.Schemas.Production != "" {
+			schemas.Production = t.Schemas.Production
+		}
+		if t.Schemas.Backup != "" {
+			schemas.Backup = t.Schemas.Backup
+		}
+		targets = append(targets, &target{
+			mappingFile: t.Mapping,
+			mapping:     m,
+			connection:  t.Connection,
+			schemas:     schemas,
+		})
+	}
+
+	if importOpts.Write || importOpts.DeployProduction || importOpts.RevertDeploy || importOpts.RemoveBackup || importOpts.Optimize {
+		for i, t := range targets {
+			if t.connection == "" {
+				log.Fatal("[error] missing connection option in configuration")
+			}
+			conf := database.Config{
+				ConnectionParams: t.connection,
+				Srid:             baseOpts.Srid,
+				ImportSchema:     t.schemas.Import,
+				ProductionSchema: t.schemas.Production,
+				BackupSchema:     t.schemas.Backup,
+				StatsTable:       baseOpts.StatsTable,
+				StateTable:       baseOpts.StateTable,
+				BulkBufferSize:   baseOpts.BulkBufferSize,
+				BulkMaxRows:      baseOpts.BulkMaxRows,
+				Workers:          baseOpts.Workers.Database,
+				TablePrefix:      baseOpts.TablePrefix,
+				TableSuffix:      baseOpts.TableSuffix,
+				QuarantineDir:    baseOpts.QuarantineDir,
+				MaxErrorRate:     baseOpts.MaxErrorRate,
+			}
+			t.db, err = database.Open(conf, &t.mapping.Conf)
+			if err != nil {
+				log.Fatal("[error] opening database: ", err)
+			}
+			defer t.db.Close()
+			if i == 0 {
+				summary.SetDB(t.db)
+			}
 		}
-		defer db.Close()
 	}
 
 	osmCache := cache.NewOSMCache(baseOpts.CacheDir)
+	if baseOpts.CoordsCache != "" {
+		if _, err := os.Stat(baseOpts.CoordsCache); err != nil {
+			log.Fatal("[error] coords cache: ", err)
+		}
+		if importOpts.Overwritecache && isSubDir(baseOpts.CacheDir, baseOpts.CoordsCache) {
+			log.Fatal("[error] -coords-cache can not be inside the -cachedir with -overwritecache")
+		}
+		log.Printf("[info] using coords from %s", baseOpts.CoordsCache)
+		osmCache.SetCoordsDir(baseOpts.CoordsCache)
+	}
 
 	if importOpts.Read != "" && osmCache.Exists() {
 		if importOpts.Overwritecache {
@@ -108,11 +176,24 @@ func Import(importOpts config.Import) {
 			readLimiter = nil
 		}
 
+		reader.SetProcs(baseOpts.Workers.Read)
+		tagmappings := make([]*mapping.Mapping, 0, len(targets))
+		for _, t := range targets {
+			tagmappings = append(tagmappings, t.mapping)
+		}
+		var selection *reader.Selection
+		if importOpts.Prefilter {
+			selection, err = reader.Prefilter(importOpts.Read, tagmappings)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
 		err := reader.ReadPbf(importOpts.Read,
 			osmCache,
 			progress,
-			tagmapping,
+			tagmappings,
 			readLimiter,
+			selection,
 		)
 		if err != nil {
 			log.Fatal(err)
@@ -120,6 +201,7 @@ func Import(importOpts config.Import) {
 
 		osmCache.Coords.SetLinearImport(false)
 		elementCounts = progress.Stop()
+		summary.SetElementCounts(elementCounts)
 		osmCache.Close()
 		step()
 		if importOpts.Diff {
@@ -137,133 +219,202 @@ func Import(importOpts config.Import) {
 	}
 
 	if importOpts.Write {
-		importFinished := log.Step("Importing OSM data")
-		writeFinished := log.Step("Writing OSM data")
-		progress := stats.NewStatsReporterWithEstimate(elementCounts)
+		for i, t := range targets {
+			if len(targets) > 1 {
+				log.Printf("[info] Writing %s", t.mappingFile)
+			}
+			// only the first mapping can be updated with diffs
+			writeTarget(importOpts, t.mapping, t.db, osmCache, elementCounts, geometryLimiter, importOpts.Diff && i == 0)
+		}
+	}
 
-		err = db.Init()
-		if err != nil {
-			log.Fatal(err)
+	for _, t := range targets {
+		db := t.db
+		if importOpts.Optimize && !importOpts.Write { // Optimize already called in Write.
+			if db, ok := db.(database.Optimizer); ok {
+				if err := db.Optimize(); err != nil {
+					log.Fatal(err)
+				}
+			} else {
+				log.Fatal("database not optimizable")
+			}
 		}
 
-		bulkDb, ok := db.(database.BulkBeginner)
-		if ok {
-			err = bulkDb.BeginBulk()
-		} else {
-			err = db.Begin()
+		if importOpts.Write || importOpts.DeployProduction {
+			if db, ok := db.(database.Asserter); ok {
+				if errs := db.Assert(); len(errs) > 0 {
+					for _, err := range errs {
+						log.Printf("[error] Assertion failed: %s", err)
+					}
+					log.Fatal("[fatal] Assertions of the mapping failed, tables are not deployed")
+				}
+			}
 		}
-		if err != nil {
-			log.Fatal(err)
+
+		if importOpts.DeployProduction {
+			if db, ok := db.(database.Deployer); ok {
+				if err := db.Deploy(); err != nil {
+					log.Fatal(err)
+				}
+				runDeployHook(baseOpts.DeployHook, "deploy", t.schemas.Production, t.db)
+			} else {
+				log.Fatal("database not deployable")
+			}
 		}
 
-		var diffCache *cache.DiffCache
-		if importOpts.Diff {
-			diffCache = cache.NewDiffCache(baseOpts.CacheDir)
-			if err = diffCache.Remove(); err != nil {
-				log.Fatal(err)
+		if importOpts.RevertDeploy {
+			if db, ok := db.(database.Deployer); ok {
+				if err := db.RevertDeploy(); err != nil {
+					log.Fatal(err)
+				}
+				runDeployHook(baseOpts.DeployHook, "revert", t.schemas.Production, t.db)
+			} else {
+				log.Fatal("database not deployable")
 			}
-			if err = diffCache.Open(); err != nil {
-				log.Fatal(err)
+		}
+
+		if importOpts.RemoveBackup {
+			if db, ok := db.(database.Deployer); ok {
+				if err := db.RemoveBackup(); err != nil {
+					log.Fatal(err)
+				}
+			} else {
+				log.Fatal("database not deployable")
 			}
 		}
+	}
 
-		err = osmCache.Open()
-		if err != nil {
+	step()
+	summary.Finish()
+}
+
+// writeTarget writes all elements from osmCache into db, and generalizes
+// and indexes all tables of tagmapping. The diff cache is only created if
+// withDiff is true.
+func writeTarget(
+	importOpts config.Import,
+	tagmapping *mapping.Mapping,
+	db database.DB,
+	osmCache *cache.OSMCache,
+	elementCounts *stats.ElementCounts,
+	geometryLimiter *limit.Limiter,
+	withDiff bool,
+) {
+	baseOpts := importOpts.Base
+	var err error
+
+	importFinished := log.Step("Importing OSM data")
+	writeFinished := log.Step("Writing OSM data")
+	progress := stats.NewStatsReporterWithEstimate(elementCounts)
+
+	err = db.Init()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	bulkDb, ok := db.(database.BulkBeginner)
+	if ok {
+		err = bulkDb.BeginBulk()
+	} else {
+		err = db.Begin()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var diffCache *cache.DiffCache
+	if withDiff {
+		diffCache = cache.NewDiffCache(baseOpts.CacheDir)
+		if err = diffCache.Remove(); err != nil {
 			log.Fatal(err)
 		}
-		if diffCache != nil {
-			diffCache.Coords.SetLinearImport(true)
-			diffCache.Ways.SetLinearImport(true)
-		}
-		osmCache.Coords.SetReadOnly(true)
-
-		relations := osmCache.Relations.Iter()
-		relWriter := writer.NewRelationWriter(osmCache, diffCache,
-			tagmapping.Conf.SingleIDSpace,
-			relations,
-			db, progress,
-			tagmapping.PolygonMatcher,
-			tagmapping.RelationMatcher,
-			tagmapping.RelationMemberMatcher,
-			baseOpts.Srid,
-		)
-		relWriter.SetLimiter(geometryLimiter)
-		relWriter.EnableConcurrent()
-		relWriter.Start()
-		relWriter.Wait() // blocks till the Relations.Iter() finishes
-		osmCache.Relations.Close()
-
-		ways := osmCache.Ways.Iter()
-		wayWriter := writer.NewWayWriter(osmCache, diffCache,
-			tagmapping.Conf.SingleIDSpace,
-			ways, db,
-			progress,
-			tagmapping.PolygonMatcher,
-			tagmapping.LineStringMatcher,
-			baseOpts.Srid,
-		)
-		wayWriter.SetLimiter(geometryLimiter)
-		wayWriter.EnableConcurrent()
-		wayWriter.Start()
-		wayWriter.Wait() // blocks till the Ways.Iter() finishes
-		osmCache.Ways.Close()
-
-		nodes := osmCache.Nodes.Iter()
-		nodeWriter := writer.NewNodeWriter(osmCache, nodes, db,
-			progress,
-			tagmapping.PointMatcher,
-			baseOpts.Srid,
-		)
-		nodeWriter.SetLimiter(geometryLimiter)
-		nodeWriter.EnableConcurrent()
-		nodeWriter.Start()
-		nodeWriter.Wait() // blocks till the Nodes.Iter() finishes
-		osmCache.Close()
-
-		err = db.End()
-		if err != nil {
+		if err = diffCache.Open(); err != nil {
 			log.Fatal(err)
 		}
+	}
 
-		progress.Stop()
-
-		if importOpts.Diff {
-			diffCache.Close()
-		}
+	err = osmCache.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if diffCache != nil {
+		diffCache.Coords.SetLinearImport(true)
+		diffCache.Ways.SetLinearImport(true)
+	}
+	osmCache.Coords.SetReadOnly(true)
+
+	relations := osmCache.Relations.Iter()
+	relWriter := writer.NewRelationWriter(osmCache, diffCache,
+		tagmapping.Conf.SingleIDSpace,
+		relations,
+		db, progress,
+		tagmapping.PolygonMatcher,
+		tagmapping.RelationMatcher,
+		tagmapping.RelationMemberMatcher,
+		baseOpts.Srid,
+	)
+	relWriter.SetLimiter(geometryLimiter)
+	relWriter.SetMaxRelationMembers(baseOpts.MaxRelationMembers)
+	relWriter.SetRelationMemberDepth(baseOpts.RelationMemberDepth)
+	relWriter.SetHugeRelationMode(writer.HugeRelationMode(baseOpts.RelationHugeMode), baseOpts.RelationTolerance)
+	relWriter.SetConcurrency(baseOpts.Workers.Write)
+	relWriter.EnableConcurrent()
+	relWriter.Start()
+	relWriter.Wait() // blocks till the Relations.Iter() finishes
+	osmCache.Relations.Close()
+
+	ways := osmCache.Ways.Iter()
+	wayWriter := writer.NewWayWriter(osmCache, diffCache,
+		tagmapping.Conf.SingleIDSpace,
+		ways, db,
+		progress,
+		tagmapping.PolygonMatcher,
+		tagmapping.LineStringMatcher,
+		baseOpts.Srid,
+	)
+	wayWriter.SetLimiter(geometryLimiter)
+	wayWriter.SetConcurrency(baseOpts.Workers.Write)
+	wayWriter.EnableConcurrent()
+	wayWriter.Start()
+	wayWriter.Wait() // blocks till the Ways.Iter() finishes
+	osmCache.Ways.Close()
+
+	nodes := osmCache.Nodes.Iter()
+	nodeWriter := writer.NewNodeWriter(osmCache, nodes, db,
+		progress,
+		tagmapping.PointMatcher,
+		baseOpts.Srid,
+	)
+	nodeWriter.SetLimiter(geometryLimiter)
+	nodeWriter.SetConcurrency(baseOpts.Workers.Write)
+	nodeWriter.EnableConcurrent()
+	nodeWriter.Start()
+	nodeWriter.Wait() // blocks till the Nodes.Iter() finishes
+	osmCache.Close()
+
+	err = db.End()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-		writeFinished()
+	progress.Stop()
 
-		if db, ok := db.(database.Generalizer); ok {
-			if err := db.Generalize(); err != nil {
-				log.Fatal(err)
-			}
-		} else {
-			log.Fatal("database not generalizeable")
-		}
+	if withDiff {
+		diffCache.Close()
+	}
 
-		// Optimize before creating indices.
-		if importOpts.Optimize {
-			if db, ok := db.(database.Optimizer); ok {
-				if err := db.Optimize(); err != nil {
-					log.Fatal(err)
-				}
-			} else {
-				log.Fatal("database not optimizable")
-			}
-		}
+	writeFinished()
 
-		// Create indices in finisher.
-		if db, ok := db.(database.Finisher); ok {
-			if err := db.Finish(); err != nil {
-				log.Fatal(err)
-			}
-		} else {
-			log.Fatal("database not finishable")
+	if db, ok := db.(database.Generalizer); ok {
+		if err := db.Generalize(); err != nil {
+			log.Fatal(err)
 		}
-		importFinished()
+	} else {
+		log.Fatal("database not generalizeable")
 	}
 
-	if importOpts.Optimize && !importOpts.Write { // Optimize already called in Write.
+	// Optimize before creating indices.
+	if importOpts.Optimize {
 		if db, ok := db.(database.Optimizer); ok {
 			if err := db.Optimize(); err != nil {
 				log.Fatal(err)
@@ -273,36 +424,50 @@ func Import(importOpts config.Import) {
 		}
 	}
 
-	if importOpts.DeployProduction {
-		if db, ok := db.(database.Deployer); ok {
-			if err := db.Deploy(); err != nil {
-				log.Fatal(err)
-			}
-		} else {
-			log.Fatal("database not deployable")
+	// Create indices in finisher.
+	if db, ok := db.(database.Finisher); ok {
+		if err := db.Finish(); err != nil {
+			log.Fatal(err)
 		}
+	} else {
+		log.Fatal("database not finishable")
 	}
 
-	if importOpts.RevertDeploy {
-		if db, ok := db.(database.Deployer); ok {
-			if err := db.RevertDeploy(); err != nil {
-				log.Fatal(err)
-			}
-		} else {
-			log.Fatal("database not deployable")
-		}
+	if err := database.RunFinishHooks(db); err != nil {
+		log.Fatal(err)
 	}
 
-	if importOpts.RemoveBackup {
-		if db, ok := db.(database.Deployer); ok {
-			if err := db.RemoveBackup(); err != nil {
-				log.Fatal(err)
+	if db, ok := db.(database.StatsWriter); ok {
+		if err := db.WriteStats("import"); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if db, ok := db.(database.StateStore); ok && withDiff {
+		s, err := state.ParseFile(filepath.Join(baseOpts.DiffDir, update.LastStateFilename))
+		if err != nil {
+			if !os.IsNotExist(err) {
+				log.Fatal("[fatal] Reading last.state.txt: ", err)
 			}
-		} else {
-			log.Fatal("database not deployable")
+		} else if err := db.WriteState(s); err != nil {
+			log.Fatal(err)
 		}
 	}
+	importFinished()
+}
 
-	step()
-
+// isSubDir returns whether path is dir or inside of dir.
+func isSubDir(dir, path string) bool {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
 }
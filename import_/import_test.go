@@ -0,0 +1,21 @@
+package import_
+
+import "testing"
+
+func TestIsSubDir(t *testing.T) {
+	for _, tt := range []struct {
+		dir, path string
+		expected  bool
+	}{
+		{"/tmp/cache", "/tmp/cache", true},
+		{"/tmp/cache", "/tmp/cache/coords", true},
+		{"/tmp/cache/", "/tmp/cache/../cache/coords", true},
+		{"/tmp/cache", "/tmp/cache2/coords", false},
+		{"/tmp/cache", "/tmp/other/coords", false},
+		{"/tmp/cache", "/tmp/..coords", false},
+	} {
+		if got := isSubDir(tt.dir, tt.path); got != tt.expected {
+			t.Errorf("isSubDir(%q, %q) = %v, expected %v", tt.dir, tt.path, got, tt.expected)
+		}
+	}
+}
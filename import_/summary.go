@@ -0,0 +1,181 @@
+package import_
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/omniscale/imposm3/config"
+	"github.com/omniscale/imposm3/database"
+	"github.com/omniscale/imposm3/log"
+	"github.com/omniscale/imposm3/stats"
+)
+
+// summary is written as JSON to the -summary file at the end of each
+// import, also if the import failed.
+type summary struct {
+	Started  time.Time                      `json:"started"`
+	Finished time.Time                      `json:"finished"`
+	Success  bool                           `json:"success"`
+	Error    string                         `json:"error,omitempty"`
+	Input    *summaryFile                   `json:"input,omitempty"`
+	Mapping  *summaryFile                   `json:"mapping"`
+	Elements *summaryElements               `json:"elements,omitempty"`
+	Steps    []summaryStep                  `json:"steps"`
+	Tables   map[string]database.TableStats `json:"tables,omitempty"`
+	Warnings int                            `json:"warnings"`
+	Errors   int                            `json:"errors"`
+	CacheDir string                         `json:"cachedir"`
+	DiffDir  string                         `json:"diffdir,omitempty"`
+	Schema   string                         `json:"schema,omitempty"`
+}
+
+type summaryFile struct {
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	SHA256   string    `json:"sha256,omitempty"`
+}
+
+type summaryElements struct {
+	Coords    int64 `json:"coords"`
+	Nodes     int64 `json:"nodes"`
+	Ways      int64 `json:"ways"`
+	Relations int64 `json:"relations"`
+}
+
+type summaryStep struct {
+	Name     string  `json:"name"`
+	Duration float64 `json:"duration_seconds"`
+}
+
+type summaryWriter struct {
+	mu       sync.Mutex
+	filename string
+	summary  summary
+	db       database.DB
+}
+
+// newSummaryWriter collects the summary for the -summary file. It returns
+// nil if filename is empty.
+func newSummaryWriter(filename string, importOpts config.Import) *summaryWriter {
+	if filename == "" {
+		return nil
+	}
+	baseOpts := importOpts.Base
+	sw := &summaryWriter{
+		filename: filename,
+		summary: summary{
+			Started:  time.Now(),
+			Steps:    []summaryStep{},
+			CacheDir: baseOpts.CacheDir,
+		},
+	}
+	if importOpts.Diff {
+		sw.summary.DiffDir = baseOpts.DiffDir
+	}
+	if importOpts.Write {
+		sw.summary.Schema = baseOpts.Schemas.Import
+	}
+	if importOpts.DeployProduction {
+		sw.summary.Schema = baseOpts.Schemas.Production
+	}
+	// only the mapping is hashed, as the PBF can be very large
+	if importOpts.Read != "" {
+		sw.summary.Input = fileInfo(importOpts.Read, false)
+	}
+	sw.summary.Mapping = fileInfo(baseOpts.MappingFile, true)
+
+	log.OnStep(func(name string, d time.Duration) {
+		sw.mu.Lock()
+		sw.summary.Steps = append(sw.summary.Steps, summaryStep{Name: name, Duration: d.Seconds()})
+		sw.mu.Unlock()
+	})
+	log.OnFatal(func(msg string) {
+		sw.write(msg)
+	})
+	return sw
+}
+
+func fileInfo(path string, hash bool) *summaryFile {
+	sf := &summaryFile{Path: path}
+	f, err := os.Open(path)
+	if err != nil {
+		return sf
+	}
+	defer f.Close()
+	if fi, err := f.Stat(); err == nil {
+		sf.Size = fi.Size()
+		sf.Modified = fi.ModTime()
+	}
+	if hash {
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err == nil {
+			sf.SHA256 = hex.EncodeToString(h.Sum(nil))
+		}
+	}
+	return sf
+}
+
+func (sw *summaryWriter) SetDB(db database.DB) {
+	if sw == nil {
+		return
+	}
+	sw.mu.Lock()
+	sw.db = db
+	sw.mu.Unlock()
+}
+
+func (sw *summaryWriter) SetElementCounts(counts *stats.ElementCounts) {
+	if sw == nil || counts == nil {
+		return
+	}
+	sw.mu.Lock()
+	sw.summary.Elements = &summaryElements{
+		Coords:    counts.Coords.Current,
+		Nodes:     counts.Nodes.Current,
+		Ways:      counts.Ways.Current,
+		Relations: counts.Relations.Current,
+	}
+	sw.mu.Unlock()
+}
+
+// Finish writes the summary of a successful import.
+func (sw *summaryWriter) Finish() {
+	if sw == nil {
+		return
+	}
+	sw.write("")
+}
+
+// write writes the summary with the given error message (empty for
+// successful imports). Errors are only logged, as write is also called from
+// log.Fatal.
+func (sw *summaryWriter) write(errMsg string) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	s := sw.summary
+	s.Finished = time.Now()
+	s.Success = errMsg == ""
+	s.Error = errMsg
+	s.Warnings = log.Count(log.LWarn)
+	s.Errors = log.Count(log.LError)
+	if db, ok := sw.db.(database.StatsReporter); ok {
+		s.Tables = db.TableStats()
+	}
+
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		log.Println("[error] Encoding summary:", err)
+		return
+	}
+	if err := ioutil.WriteFile(sw.filename, append(b, '\n'), 0644); err != nil {
+		log.Println("[error] Writing summary:", err)
+	}
+}
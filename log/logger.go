@@ -2,11 +2,13 @@ package log
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"math"
 	"os"
+	"sync"
 	"time"
 )
 
@@ -41,12 +43,40 @@ func init() {
 	DefaultLogger = log.New(defaultFilter, "", 0)
 }
 
+// Format of the log output.
+type Format string
+
+const (
+	// FormatText writes log lines with timestamp and elapsed time.
+	FormatText = Format("text")
+	// FormatJSON writes each log line as a JSON object in the structured
+	// logging format of Google Cloud Logging. The logging agents of
+	// GCE/GKE/Cloud Run parse these lines and add the resource labels.
+	FormatJSON = Format("json")
+)
+
+// severities maps our levels to Cloud Logging severities.
+var severities = map[Level]string{
+	LDebug:    "DEBUG",
+	LProgress: "INFO",
+	LStep:     "INFO",
+	LInfo:     "INFO",
+	LWarn:     "WARNING",
+	LError:    "ERROR",
+	LFatal:    "CRITICAL",
+}
+
 type logFilter struct {
 	start     time.Time
 	writer    io.Writer
 	badLevels map[Level]struct{}
 	minLevel  Level
 	levels    []Level
+	format    Format
+	labels    map[string]string
+
+	countsMu sync.Mutex
+	counts   map[Level]int
 }
 
 func (f *logFilter) SetMinLevel(lvl Level) {
@@ -66,8 +96,12 @@ func (f *logFilter) init() {
 }
 
 func (f *logFilter) Check(line []byte) bool {
+	_, ok := f.badLevels[lineLevel(line)]
+	return !ok
+}
 
-	// Check for a log level
+// lineLevel returns the log level of line, e.g. "info" for "[info] message".
+func lineLevel(line []byte) Level {
 	var level Level
 	x := bytes.IndexByte(line, '[')
 	if x >= 0 {
@@ -76,15 +110,26 @@ func (f *logFilter) Check(line []byte) bool {
 			level = Level(line[x+1 : x+y])
 		}
 	}
+	return level
+}
 
-	_, ok := f.badLevels[level]
-	return !ok
+func (f *logFilter) count(lvl Level) {
+	f.countsMu.Lock()
+	if f.counts == nil {
+		f.counts = make(map[Level]int)
+	}
+	f.counts[lvl]++
+	f.countsMu.Unlock()
 }
 
 func (f *logFilter) Write(p []byte) (n int, err error) {
+	f.count(lineLevel(p))
 	if !f.Check(p) {
 		return 0, nil
 	}
+	if f.format == FormatJSON {
+		return f.writeJSON(p)
+	}
 	// The Go log package always guarantees that we only
 	// get a single line.
 	b := bytes.Buffer{}
@@ -103,10 +148,54 @@ func (f *logFilter) Write(p []byte) (n int, err error) {
 
 }
 
+type jsonEntry struct {
+	Severity string            `json:"severity"`
+	Message  string            `json:"message"`
+	Time     string            `json:"time"`
+	Labels   map[string]string `json:"logging.googleapis.com/labels,omitempty"`
+}
+
+func (f *logFilter) writeJSON(p []byte) (n int, err error) {
+	level := lineLevel(p)
+	severity, ok := severities[level]
+	if !ok {
+		severity = "DEFAULT"
+	}
+	msg := bytes.TrimSpace(p)
+	if prefix := []byte("[" + string(level) + "]"); level != "" && bytes.HasPrefix(msg, prefix) {
+		msg = bytes.TrimSpace(msg[len(prefix):])
+	}
+	b, err := json.Marshal(jsonEntry{
+		Severity: severity,
+		Message:  string(msg),
+		Time:     time.Now().Format(time.RFC3339Nano),
+		Labels:   f.labels,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return f.writer.Write(append(b, '\n'))
+}
+
 func SetMinLevel(lvl Level) {
 	defaultFilter.SetMinLevel(lvl)
 }
 
+// SetFormat sets the output format of all log messages. labels are added to
+// each entry in FormatJSON.
+func SetFormat(format Format, labels map[string]string) {
+	defaultFilter.format = format
+	defaultFilter.labels = labels
+}
+
+// Count returns the number of messages logged with lvl, including messages
+// that were filtered by SetMinLevel.
+func Count(lvl Level) int {
+	defaultFilter.countsMu.Lock()
+	defer defaultFilter.countsMu.Unlock()
+	return defaultFilter.counts[lvl]
+}
+
 func Println(v ...interface{}) {
 	DefaultLogger.Println(v...)
 }
@@ -115,18 +204,54 @@ func Printf(format string, v ...interface{}) {
 	DefaultLogger.Printf(format, v...)
 }
 
+var fatalHooks []func(msg string)
+
+// OnFatal registers a function that is called with the message of Fatal or
+// Fatalf, before the process exits.
+func OnFatal(f func(msg string)) {
+	fatalHooks = append(fatalHooks, f)
+}
+
+func fatal(msg string) {
+	DefaultLogger.Output(3, msg)
+	for _, f := range fatalHooks {
+		f(msg)
+	}
+	os.Exit(1)
+}
+
 func Fatal(v ...interface{}) {
-	DefaultLogger.Fatal(v...)
+	fatal(fmt.Sprint(v...))
 }
 
 func Fatalf(format string, v ...interface{}) {
-	DefaultLogger.Fatalf(format, v...)
+	fatal(fmt.Sprintf(format, v...))
+}
+
+var (
+	stepHooksMu sync.Mutex
+	stepHooks   []func(name string, d time.Duration)
+)
+
+// OnStep registers a function that is called with the name and duration
+// of each finished Step.
+func OnStep(f func(name string, d time.Duration)) {
+	stepHooksMu.Lock()
+	stepHooks = append(stepHooks, f)
+	stepHooksMu.Unlock()
 }
 
 func Step(name string) func() {
 	start := time.Now()
 	Println("[step] Starting:", name)
 	return func() {
-		Printf("[step] Finished: %s in %s", name, time.Since(start))
+		d := time.Since(start)
+		Printf("[step] Finished: %s in %s", name, d)
+		stepHooksMu.Lock()
+		hooks := stepHooks
+		stepHooksMu.Unlock()
+		for _, f := range hooks {
+			f(name, d)
+		}
 	}
 }
@@ -32,6 +32,8 @@ func init() {
 		"member_index":         {"member_index", "int32", nil, nil, RelationMemberIndex, true},
 		"geometry":             {"geometry", "geometry", Geometry, nil, nil, false},
 		"validated_geometry":   {"validated_geometry", "validated_geometry", Geometry, nil, nil, false},
+		"centroid":             {"centroid", "geometry", Centroid, nil, nil, false},
+		"simplified_geometry":  {"simplified_geometry", "geometry", nil, MakeSimplifiedGeometry, nil, false},
 		"hstore_tags":          {"hstore_tags", "hstore_string", nil, MakeHStoreString, nil, false},
 		"wayzorder":            {"wayzorder", "int32", nil, MakeWayZOrder, nil, false},
 		"pseudoarea":           {"pseudoarea", "float32", nil, MakePseudoArea, nil, false},
@@ -42,8 +44,10 @@ func init() {
 		"string_suffixreplace": {"string_suffixreplace", "string", nil, MakeSuffixReplace, nil, false},
 
 		"categorize_int":             {Name: "categorize_int", GoType: "int32", MakeFunc: MakeCategorizeInt},
+		"building_height":            {Name: "building_height", GoType: "float32", MakeFunc: MakeBuildingHeight},
 		"geojson_intersects":         {Name: "geojson_intersects", GoType: "bool", MakeFunc: MakeIntersectsField},
 		"geojson_intersects_feature": {Name: "geojson_intersects_feature", GoType: "string", MakeFunc: MakeIntersectsFeatureField},
+		"region_code":                {Name: "region_code", GoType: "string", MakeFunc: MakeRegionCode},
 	}
 }
 
@@ -0,0 +1,54 @@
+package mapping
+
+import (
+	"errors"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/geom"
+	"github.com/omniscale/imposm3/geom/geos"
+	"github.com/omniscale/imposm3/mapping/config"
+)
+
+// Centroid returns the centroid of the geometry as a point.
+func Centroid(val string, elem *osm.Element, geom *geom.Geometry, match Match) interface{} {
+	return derivedGeometry(geom, func(g *geos.Geos, geom *geos.Geom) *geos.Geom {
+		return g.Centroid(geom)
+	})
+}
+
+// MakeSimplifiedGeometry returns the geometry simplified with the
+// tolerance from the args.
+func MakeSimplifiedGeometry(columnName string, columnType ColumnType, column config.Column) (MakeValue, error) {
+	if _, ok := column.Args["tolerance"]; !ok {
+		return nil, errors.New("missing 'tolerance' in 'args' for simplified_geometry")
+	}
+	tolerance, err := numberArg(column, "tolerance")
+	if err != nil {
+		return nil, err
+	}
+	simplified := func(val string, elem *osm.Element, geom *geom.Geometry, match Match) interface{} {
+		return derivedGeometry(geom, func(g *geos.Geos, geom *geos.Geom) *geos.Geom {
+			return g.SimplifyPreserveTopology(geom, tolerance)
+		})
+	}
+	return simplified, nil
+}
+
+// derivedGeometry returns the EWKB of the geometry that f creates from the
+// geometry of the element. Returns an empty geometry for elements without
+// geometry, like the geometry column.
+func derivedGeometry(elemGeom *geom.Geometry, f func(*geos.Geos, *geos.Geom) *geos.Geom) interface{} {
+	if elemGeom == nil || elemGeom.Geom == nil {
+		return ""
+	}
+	g := geos.NewGeos()
+	defer g.Finish()
+	g.SetHandleSrid(g.Srid(elemGeom.Geom))
+
+	derived := f(g, elemGeom.Geom)
+	if derived == nil {
+		return nil
+	}
+	defer g.Destroy(derived)
+	return string(g.AsEwkbHex(derived))
+}
@@ -0,0 +1,114 @@
+package mapping
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/geom"
+	"github.com/omniscale/imposm3/mapping/config"
+)
+
+// defaultLevelHeight is the height in meters of a single building level.
+const defaultLevelHeight = 3.0
+
+// buildingHeightKeys are the tags required by building_height columns.
+var buildingHeightKeys = []Key{"height", "building:levels", "roof:height", "roof:levels"}
+
+func MakeBuildingHeight(fieldName string, fieldType ColumnType, field config.Column) (MakeValue, error) {
+	levelHeight := defaultLevelHeight
+	if _, ok := field.Args["level_height"]; ok {
+		h, err := numberArg(field, "level_height")
+		if err != nil {
+			return nil, err
+		}
+		levelHeight = h
+	}
+
+	var defaultHeight interface{}
+	if _, ok := field.Args["default"]; ok {
+		h, err := numberArg(field, "default")
+		if err != nil {
+			return nil, err
+		}
+		defaultHeight = float32(h)
+	}
+
+	makeValue := func(val string, elem *osm.Element, geom *geom.Geometry, m Match) interface{} {
+		if h, ok := parseHeight(elem.Tags["height"]); ok {
+			return float32(h)
+		}
+		levels, ok := parseLevels(elem.Tags["building:levels"])
+		if !ok {
+			return defaultHeight
+		}
+		h := levels * levelHeight
+		if roof, ok := parseHeight(elem.Tags["roof:height"]); ok {
+			h += roof
+		} else if roofLevels, ok := parseLevels(elem.Tags["roof:levels"]); ok {
+			h += roofLevels * levelHeight
+		}
+		return float32(h)
+	}
+	return makeValue, nil
+}
+
+func numberArg(field config.Column, name string) (float64, error) {
+	switch v := field.Args[name].(type) {
+	case int:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	}
+	return 0, fmt.Errorf("'%s' in 'args' for %s not a number but %v", name, field.Type, field.Args[name])
+}
+
+var (
+	heightRe     = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*(m|meters?|metres?|ft|feet|')?$`)
+	feetInchesRe = regexp.MustCompile(`^(\d+)\s*'\s*(\d+(?:\.\d+)?)\s*(?:"|in)$`)
+)
+
+const metersPerFoot = 0.3048
+
+// parseHeight parses heights in meters (10, 10.5 m, 10,5), feet (30 ft,
+// 30') or feet and inches (30'6").
+func parseHeight(val string) (float64, bool) {
+	val = strings.Replace(strings.TrimSpace(val), ",", ".", 1)
+	if val == "" {
+		return 0, false
+	}
+	if match := feetInchesRe.FindStringSubmatch(val); match != nil {
+		feet, _ := strconv.ParseFloat(match[1], 64)
+		inches, _ := strconv.ParseFloat(match[2], 64)
+		return (feet + inches/12) * metersPerFoot, true
+	}
+	match := heightRe.FindStringSubmatch(val)
+	if match == nil {
+		return 0, false
+	}
+	h, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, false
+	}
+	switch match[2] {
+	case "ft", "feet", "'":
+		h *= metersPerFoot
+	}
+	return h, true
+}
+
+// parseLevels parses the number of levels. Fractional levels are allowed.
+func parseLevels(val string) (float64, bool) {
+	val = strings.Replace(strings.TrimSpace(val), ",", ".", 1)
+	if val == "" {
+		return 0, false
+	}
+	levels, err := strconv.ParseFloat(val, 64)
+	if err != nil || levels < 0 || math.IsInf(levels, 0) || math.IsNaN(levels) {
+		return 0, false
+	}
+	return levels, true
+}
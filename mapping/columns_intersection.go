@@ -130,6 +130,78 @@ func MakeIntersectsField(fieldName string, fieldType ColumnType, field config.Co
 	return makeValue, nil
 }
 
+// MakeRegionCode returns the property of the feature that contains a point
+// on the surface of the geometry. Other than geojson_intersects_feature, each
+// element is assigned to a single region, even if it crosses a boundary.
+// Elements without a point inside of any feature (e.g. at the coastline) fall
+// back to the first intersecting feature.
+func MakeRegionCode(fieldName string, fieldType ColumnType, field config.Column) (MakeValue, error) {
+	idx, features, preparedGeoms, err := loadFeatures(field)
+	if err != nil {
+		return nil, err
+	}
+
+	propertyName := "ISO3166-1"
+	if _propertyName, ok := field.Args["property"]; ok {
+		propertyName, ok = _propertyName.(string)
+		if !ok {
+			return nil, errors.New("property in args for region_code not a string")
+		}
+	}
+
+	g := geos.NewGeos()
+	// g is shared by all writers, pointMu synchronizes the creation and
+	// destruction of the points
+	var pointMu sync.Mutex
+
+	makeValue := func(val string, elem *osm.Element, geom *geom.Geometry, m Match) interface{} {
+		if geom.Geom == nil {
+			return nil
+		}
+		indices := g.IndexQuery(idx, geom.Geom)
+		if len(indices) == 0 {
+			return nil
+		}
+
+		pointMu.Lock()
+		point := g.PointOnSurface(geom.Geom)
+		pointMu.Unlock()
+		if point != nil {
+			defer func() {
+				pointMu.Lock()
+				g.Destroy(point)
+				pointMu.Unlock()
+			}()
+			for _, idx := range indices {
+				preparedGeom := &preparedGeoms[idx]
+				preparedGeom.Lock()
+				contains := g.PreparedContains(preparedGeom.geom, point)
+				preparedGeom.Unlock()
+				if contains {
+					if v, ok := features[idx].properties[propertyName]; ok {
+						return v
+					}
+				}
+			}
+		}
+
+		for _, idx := range indices {
+			preparedGeom := &preparedGeoms[idx]
+			preparedGeom.Lock()
+			intersects := g.PreparedIntersects(preparedGeom.geom, geom.Geom)
+			preparedGeom.Unlock()
+			if intersects {
+				if v, ok := features[idx].properties[propertyName]; ok {
+					return v
+				}
+			}
+		}
+		return nil
+	}
+
+	return makeValue, nil
+}
+
 // TODO duplicate of imposm3/geom/limit
 func geosRing(g *geos.Geos, ls geojson.LineString) (*geos.Geom, error) {
 	coordSeq, err := g.CreateCoordSeq(uint32(len(ls)), 2)
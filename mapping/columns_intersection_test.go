@@ -26,7 +26,7 @@ func TestIntersectsFeatureField(t *testing.T) {
 	}
 	match := Match{}
 	elem := osm.Element{}
-	geom := geomp.Geometry{nil, nil}
+	geom := geomp.Geometry{}
 	g := geos.NewGeos()
 
 	geom.Geom = g.Point(proj.WgsToMerc(6.76976, 52.60763)) // Germany
@@ -63,7 +63,7 @@ func TestIntersectsField(t *testing.T) {
 	}
 	match := Match{}
 	elem := osm.Element{}
-	geom := geomp.Geometry{nil, nil}
+	geom := geomp.Geometry{}
 	g := geos.NewGeos()
 
 	geom.Geom = g.Point(proj.WgsToMerc(6.76976, 52.60763)) // Germany
@@ -85,6 +85,37 @@ func TestIntersectsField(t *testing.T) {
 	}
 }
 
+func TestRegionCode(t *testing.T) {
+	makeValue, err := MakeRegionCode("",
+		AvailableColumnTypes["region_code"],
+		config.Column{
+			Name: "country",
+			Type: "region_code",
+			Args: map[string]interface{}{"geojson": "be_nl_bounds.geojson", "property": "FIPS_CNTRY"},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	match := Match{}
+	elem := osm.Element{}
+	geom := geomp.Geometry{}
+	g := geos.NewGeos()
+
+	geom.Geom = g.Point(proj.WgsToMerc(6.76976, 52.60763)) // Germany
+	if value := makeValue("", &elem, &geom, match); value != nil {
+		t.Error("expected nil, got", value)
+	}
+	geom.Geom = g.Point(proj.WgsToMerc(4.8542, 52.5726))
+	if value := makeValue("", &elem, &geom, match); value != "NL" {
+		t.Error("got", value)
+	}
+	geom.Geom = g.Point(proj.WgsToMerc(5.04529, 51.40216))
+	if value := makeValue("", &elem, &geom, match); value != "BE" {
+		t.Error("got", value)
+	}
+}
+
 func BenchmarkIntersectsFeatureField(b *testing.B) {
 	makeValue, err := MakeIntersectsFeatureField("",
 		AvailableColumnTypes["intersection"],
@@ -106,7 +137,7 @@ func BenchmarkIntersectsFeatureField(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		// 2,49 : 9,54
 		p := g.Point(proj.WgsToMerc(rand.Float64()*7+2, rand.Float64()*5+49))
-		geom := geomp.Geometry{p, nil}
+		geom := geomp.Geometry{Geom: p}
 		if value := makeValue("", &elem, &geom, match); value == "BE" || value == "NL" {
 			hits += 1
 		}
@@ -137,7 +168,7 @@ func BenchmarkIntersectsField(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		// 2,49 : 9,54
 		p := g.Point(proj.WgsToMerc(rand.Float64()*7+2, rand.Float64()*5+49))
-		geom := geomp.Geometry{p, nil}
+		geom := geomp.Geometry{Geom: p}
 		if value := makeValue("", &elem, &geom, match); value == true {
 			hits += 1
 		}
@@ -352,3 +352,75 @@ func TestHstoreString(t *testing.T) {
 	}
 
 }
+
+func TestBuildingHeight(t *testing.T) {
+	column := config.Column{
+		Name: "height", Type: "building_height",
+		Args: map[string]interface{}{"level_height": 2.5, "default": 4}}
+	buildingHeight, err := MakeBuildingHeight("height", ColumnType{}, column)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		tags     osm.Tags
+		expected interface{}
+	}{
+		{osm.Tags{}, float32(4)},
+		{osm.Tags{"height": "12"}, float32(12)},
+		{osm.Tags{"height": "12.5 m"}, float32(12.5)},
+		{osm.Tags{"height": "12,5"}, float32(12.5)},
+		{osm.Tags{"height": "10 ft"}, float32(3.048)},
+		{osm.Tags{"height": "10'"}, float32(3.048)},
+		{osm.Tags{"height": "10'6\""}, float32(3.2004)},
+		{osm.Tags{"height": "12", "building:levels": "10"}, float32(12)},
+		{osm.Tags{"height": "tall", "building:levels": "4"}, float32(10)},
+		{osm.Tags{"building:levels": "4", "roof:levels": "1"}, float32(12.5)},
+		{osm.Tags{"building:levels": "4", "roof:height": "3", "roof:levels": "1"}, float32(13)},
+		{osm.Tags{"building:levels": "NaN"}, float32(4)},
+	} {
+		elem := osm.Element{Tags: test.tags}
+		if v := buildingHeight("", &elem, nil, Match{}); v != test.expected {
+			t.Errorf("%v: %v != %v", test.tags, v, test.expected)
+		}
+	}
+
+	column.Args = map[string]interface{}{}
+	buildingHeight, err = MakeBuildingHeight("height", ColumnType{}, column)
+	if err != nil {
+		t.Fatal(err)
+	}
+	elem := osm.Element{Tags: osm.Tags{"building:levels": "2"}}
+	if v := buildingHeight("", &elem, nil, Match{}); v != float32(6) {
+		t.Errorf("%v != 6", v)
+	}
+	elem = osm.Element{Tags: osm.Tags{}}
+	if v := buildingHeight("", &elem, nil, Match{}); v != nil {
+		t.Errorf("%v != nil", v)
+	}
+
+	column.Args = map[string]interface{}{"level_height": "high"}
+	if _, err := MakeBuildingHeight("height", ColumnType{}, column); err == nil {
+		t.Error("expected error for level_height")
+	}
+}
+
+func TestMakeSimplifiedGeometry(t *testing.T) {
+	column := config.Column{Name: "geometry_simple", Type: "simplified_geometry"}
+	if _, err := MakeSimplifiedGeometry("geometry_simple", ColumnType{}, column); err == nil {
+		t.Error("expected error for missing tolerance")
+	}
+
+	column.Args = map[string]interface{}{"tolerance": 100}
+	simplified, err := MakeSimplifiedGeometry("geometry_simple", ColumnType{}, column)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// elements without geometry, e.g. for relation tables
+	if v := simplified("", nil, &geom.Geometry{}, Match{}); v != "" {
+		t.Errorf("expected empty geometry, got %v", v)
+	}
+	if v := Centroid("", nil, &geom.Geometry{}, Match{}); v != "" {
+		t.Errorf("expected empty geometry, got %v", v)
+	}
+}
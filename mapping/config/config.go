@@ -14,6 +14,9 @@ type Mapping struct {
 	// SingleIDSpace mangles the overlapping node/way/relation IDs
 	// to be unique (nodes positive, ways negative, relations negative -1e17)
 	SingleIDSpace bool `yaml:"use_single_id_space"`
+	// LenientColumnTypes imports columns with unsupported types as
+	// string columns, instead of failing.
+	LenientColumnTypes bool `yaml:"lenient_column_types"`
 }
 
 type Column struct {
@@ -23,19 +26,54 @@ type Column struct {
 	Type       string                 `yaml:"type"`
 	Args       map[string]interface{} `yaml:"args"`
 	FromMember bool                   `yaml:"from_member"`
+	// Description is stored as comment of the column.
+	Description string `yaml:"description"`
 }
 
 type Tables map[string]*Table
 type Table struct {
 	Name          string
-	Type          string                `yaml:"type"`
-	Mapping       KeyValues             `yaml:"mapping"`
-	Mappings      map[string]SubMapping `yaml:"mappings"`
-	TypeMappings  TypeMappings          `yaml:"type_mappings"`
-	Columns       []*Column             `yaml:"columns"`
-	OldFields     []*Column             `yaml:"fields"`
-	Filters       *Filters              `yaml:"filters"`
-	RelationTypes []string              `yaml:"relation_types"`
+	Type          string       `yaml:"type"`
+	Mapping       KeyValues    `yaml:"mapping"`
+	Mappings      SubMappings  `yaml:"mappings"`
+	TypeMappings  TypeMappings `yaml:"type_mappings"`
+	Columns       []*Column    `yaml:"columns"`
+	OldFields     []*Column    `yaml:"fields"`
+	Filters       *Filters     `yaml:"filters"`
+	RelationTypes []string     `yaml:"relation_types"`
+	// IDColumn is the name of the column with the OSM ID that is used to
+	// delete elements during diff imports. Defaults to the first column
+	// with type id.
+	IDColumn string `yaml:"id_column"`
+	// GeometryLimit limits the size of the geometries of this table.
+	GeometryLimit *GeometryLimit `yaml:"geometry_limit"`
+	// Duplicates is "first" or "merge" to insert a single row if multiple
+	// sub-mappings match the same element.
+	Duplicates string `yaml:"duplicates"`
+	// Assertions are checked after the import, before the table is
+	// deployed.
+	Assertions *TableAssertions `yaml:"assertions"`
+	// Description is stored as comment of the table.
+	Description string `yaml:"description"`
+}
+
+// TableAssertions fail the import if the imported table has less than
+// MinRows rows, if the fraction of rows with a NULL or empty geometry is
+// larger than MaxNullGeometry, or if any of the RequiredValues is missing
+// in its column.
+type TableAssertions struct {
+	MinRows         int64               `yaml:"min_rows"`
+	MaxNullGeometry *float64            `yaml:"max_null_geometry"`
+	RequiredValues  map[string][]string `yaml:"required_values"`
+}
+
+// GeometryLimit limits the number of vertices and the size of the encoded
+// geometry. Larger geometries are simplified until they are within the
+// limits, or skipped if Skip is set. A limit of 0 is unlimited.
+type GeometryLimit struct {
+	MaxVertices int  `yaml:"max_vertices"`
+	MaxBytes    int  `yaml:"max_bytes"`
+	Skip        bool `yaml:"skip"`
 }
 
 type GeneralizedTables map[string]*GeneralizedTable
@@ -44,6 +82,14 @@ type GeneralizedTable struct {
 	SourceTableName string  `yaml:"source"`
 	Tolerance       float64 `yaml:"tolerance"`
 	SQLFilter       string  `yaml:"sql_filter"`
+	// SQL is a query for each database backend that replaces the default
+	// simplification of the source table.
+	SQL map[string]string `yaml:"sql"`
+	// UpdateSQL is a query for each database backend that returns the rows
+	// of a single element for diff imports. Required for tables with SQL.
+	UpdateSQL map[string]string `yaml:"update_sql"`
+	// Description is stored as comment of the table.
+	Description string `yaml:"description"`
 }
 
 type Filters struct {
@@ -60,9 +106,20 @@ type Areas struct {
 }
 
 type Tags struct {
-	LoadAll bool  `yaml:"load_all"`
-	Exclude []Key `yaml:"exclude"`
-	Include []Key `yaml:"include"`
+	LoadAll   bool              `yaml:"load_all"`
+	Exclude   []Key             `yaml:"exclude"`
+	Include   []Key             `yaml:"include"`
+	Normalize *TagNormalization `yaml:"normalize"`
+}
+
+// TagNormalization cleans the tags of all elements before they are
+// filtered and matched.
+type TagNormalization struct {
+	LowercaseKeys bool `yaml:"lowercase_keys"`
+	TrimSpace     bool `yaml:"trim_space"`
+	StripControl  bool `yaml:"strip_control"`
+	// MaxLength drops tags with longer keys or values. Unlimited if 0.
+	MaxLength int `yaml:"max_length"`
 }
 
 type Key string
@@ -111,6 +168,40 @@ type SubMapping struct {
 	Mapping KeyValues
 }
 
+type SubMappings map[string]SubMapping
+
+// UnmarshalYAML continues the order of the values across all sub-mappings,
+// so that the order reflects the order in the mapping file.
+func (sm *SubMappings) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	slice := yaml.MapSlice{}
+	if err := unmarshal(&slice); err != nil {
+		return err
+	}
+	mappings := map[string]SubMapping{}
+	if err := unmarshal(&mappings); err != nil {
+		return err
+	}
+	offset := 0
+	for _, item := range slice {
+		k, ok := item.Key.(string)
+		if !ok {
+			return fmt.Errorf("sub-mapping name '%v' not a string", item.Key)
+		}
+		next := offset
+		for _, vals := range mappings[k].Mapping {
+			for i := range vals {
+				vals[i].Order += offset
+				if vals[i].Order >= next {
+					next = vals[i].Order + 1
+				}
+			}
+		}
+		offset = next
+	}
+	*sm = mappings
+	return nil
+}
+
 type TypeMappings struct {
 	Points      KeyValues `yaml:"points"`
 	LineStrings KeyValues `yaml:"linestrings"`
@@ -13,6 +13,10 @@ type TagFilterer interface {
 }
 
 func (m *Mapping) NodeTagFilter() TagFilterer {
+	return m.normalizeTags(m.nodeTagFilter())
+}
+
+func (m *Mapping) nodeTagFilter() TagFilterer {
 	if m.Conf.Tags.LoadAll {
 		return newExcludeFilter(m.Conf.Tags.Exclude)
 	}
@@ -25,6 +29,10 @@ func (m *Mapping) NodeTagFilter() TagFilterer {
 }
 
 func (m *Mapping) WayTagFilter() TagFilterer {
+	return m.normalizeTags(m.wayTagFilter())
+}
+
+func (m *Mapping) wayTagFilter() TagFilterer {
 	if m.Conf.Tags.LoadAll {
 		return newExcludeFilter(m.Conf.Tags.Exclude)
 	}
@@ -39,6 +47,10 @@ func (m *Mapping) WayTagFilter() TagFilterer {
 }
 
 func (m *Mapping) RelationTagFilter() TagFilterer {
+	return m.normalizeTags(m.relationTagFilter())
+}
+
+func (m *Mapping) relationTagFilter() TagFilterer {
 	if m.Conf.Tags.LoadAll {
 		return newExcludeFilter(m.Conf.Tags.Exclude)
 	}
@@ -122,3 +134,36 @@ func (f *excludeFilter) Filter(tags *osm.Tags) {
 		}
 	}
 }
+
+// CombineTagFilters returns a TagFilterer that keeps all tags that are kept
+// by any of the filters, e.g. to read a cache for multiple mappings.
+func CombineTagFilters(filters ...TagFilterer) TagFilterer {
+	if len(filters) == 1 {
+		return filters[0]
+	}
+	return combinedFilter(filters)
+}
+
+type combinedFilter []TagFilterer
+
+func (f combinedFilter) Filter(tags *osm.Tags) {
+	if tags == nil {
+		return
+	}
+	// filters can normalize keys, so the result is the union of all
+	// filtered tags and not a subset of the original tags
+	result := make(osm.Tags, len(*tags))
+	for _, filter := range f {
+		filtered := make(osm.Tags, len(*tags))
+		for k, v := range *tags {
+			filtered[k] = v
+		}
+		filter.Filter(&filtered)
+		for k, v := range filtered {
+			if _, ok := result[k]; !ok {
+				result[k] = v
+			}
+		}
+	}
+	*tags = result
+}
@@ -587,6 +587,23 @@ func TestExcludeFilter(t *testing.T) {
 	}
 }
 
+func TestCombineTagFilters(t *testing.T) {
+	f := CombineTagFilters(
+		newExcludeFilter([]config.Key{"source", "tiger:*"}),
+		newExcludeFilter([]config.Key{"source", "name"}),
+	)
+	tags := osm.Tags{"source": "1", "tiger:foo": "1", "name": "1", "highway": "1"}
+	f.Filter(&tags)
+	if !reflect.DeepEqual(tags, osm.Tags{"tiger:foo": "1", "name": "1", "highway": "1"}) {
+		t.Error("unexpected filter result", tags)
+	}
+
+	single := newExcludeFilter([]config.Key{"source"})
+	if CombineTagFilters(single) != single {
+		t.Error("expected single filter")
+	}
+}
+
 func BenchmarkFilterNodes(b *testing.B) {
 	var tags osm.Tags
 
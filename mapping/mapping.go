@@ -1,8 +1,11 @@
 package mapping
 
 import (
+	"fmt"
 	"io/ioutil"
 	"regexp"
+	"sort"
+	"strings"
 
 	osm "github.com/omniscale/go-osm"
 	"github.com/omniscale/imposm3/log"
@@ -133,14 +136,141 @@ func (m *Mapping) prepare() error {
 				return errors.Errorf("table with type:geometry requires type_mappings for table %s", name)
 			}
 		}
+		if t.IDColumn != "" {
+			if err := checkIDColumn(t); err != nil {
+				return err
+			}
+		}
+		if t.Duplicates != "" && t.Duplicates != "first" && t.Duplicates != "merge" {
+			return errors.Errorf("duplicates of table %s needs to be first or merge, not %s", name, t.Duplicates)
+		}
+		if l := t.GeometryLimit; l != nil && (l.MaxVertices < 0 || l.MaxBytes < 0) {
+			return errors.Errorf("geometry_limit of table %s needs positive limits", name)
+		}
+		if t.Assertions != nil {
+			if err := checkAssertions(t); err != nil {
+				return err
+			}
+		}
+	}
+
+	if n := m.Conf.Tags.Normalize; n != nil && n.MaxLength < 0 {
+		return errors.New("max_length of tags normalize needs to be positive")
 	}
 
 	for name, t := range m.Conf.GeneralizedTables {
 		t.Name = name
+		if len(t.SQL) > 0 && t.SQLFilter != "" {
+			return errors.Errorf("sql_filter can not be combined with sql for generalized table %s", name)
+		}
+	}
+	return m.checkColumnTypes()
+}
+
+func checkAssertions(t *config.Table) error {
+	a := t.Assertions
+	if a.MinRows < 0 {
+		return errors.Errorf("min_rows of table %s needs to be positive", t.Name)
+	}
+	if a.MaxNullGeometry != nil && (*a.MaxNullGeometry < 0 || *a.MaxNullGeometry > 1) {
+		return errors.Errorf("max_null_geometry of table %s needs to be between 0 and 1", t.Name)
+	}
+	for col := range a.RequiredValues {
+		found := false
+		for _, c := range t.Columns {
+			if c.Name == col {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return errors.Errorf("column %s of required_values not found in columns of table %s", col, t.Name)
+		}
 	}
 	return nil
 }
 
+// checkColumnTypes checks the types of all columns and returns a single
+// error with all unsupported types. Unsupported types are replaced by string
+// if LenientColumnTypes is set.
+func (m *Mapping) checkColumnTypes() error {
+	names := make([]string, 0, len(m.Conf.Tables))
+	for name := range m.Conf.Tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var unsupported []string
+	for _, name := range names {
+		for _, c := range m.Conf.Tables[name].Columns {
+			if _, ok := AvailableColumnTypes[c.Type]; ok {
+				continue
+			}
+			if m.Conf.LenientColumnTypes {
+				log.Printf("[warn] unsupported type %s for column %s of table %s, using string", c.Type, c.Name, name)
+				c.Type = "string"
+				continue
+			}
+			unsupported = append(unsupported, fmt.Sprintf("%s of table %s (%s)", c.Name, name, c.Type))
+		}
+	}
+	if len(unsupported) > 0 {
+		return errors.Errorf("unsupported column types: %s", strings.Join(unsupported, ", "))
+	}
+	return nil
+}
+
+// checkIDColumn checks that the id_column of t is a column with type id.
+func checkIDColumn(t *config.Table) error {
+	for _, c := range t.Columns {
+		if c.Name != t.IDColumn {
+			continue
+		}
+		if c.Type != "id" {
+			return errors.Errorf("id_column %s of table %s needs to be a column with type id, not %s", t.IDColumn, t.Name, c.Type)
+		}
+		return nil
+	}
+	return errors.Errorf("id_column %s of table %s not found in columns", t.IDColumn, t.Name)
+}
+
+// Subset returns a mapping with only the tables, and all generalized tables
+// of these tables. The tag filters of the subset only keep the tags of these
+// tables, use the filters of the full mapping to keep the cache complete.
+func (m *Mapping) Subset(tables []string) (*Mapping, error) {
+	conf := m.Conf
+	conf.Tables = make(config.Tables)
+	for _, name := range tables {
+		t, ok := m.Conf.Tables[name]
+		if !ok {
+			return nil, errors.Errorf("unknown table %s", name)
+		}
+		conf.Tables[name] = t
+	}
+
+	conf.GeneralizedTables = make(config.GeneralizedTables)
+	for name, t := range m.Conf.GeneralizedTables {
+		source := t.SourceTableName
+		// follow generalized tables of generalized tables
+		for i := 0; i < len(m.Conf.GeneralizedTables); i++ {
+			gt, ok := m.Conf.GeneralizedTables[source]
+			if !ok {
+				break
+			}
+			source = gt.SourceTableName
+		}
+		if _, ok := conf.Tables[source]; ok {
+			conf.GeneralizedTables[name] = t
+		}
+	}
+
+	subset := &Mapping{Conf: conf}
+	if err := subset.createMatcher(); err != nil {
+		return nil, err
+	}
+	return subset, nil
+}
+
 func (m *Mapping) createMatcher() error {
 	var err error
 	m.PointMatcher, err = m.pointMatcher()
@@ -204,7 +334,7 @@ func (m *Mapping) tables(tableType TableType) (map[string]*rowBuilder, error) {
 }
 
 func makeRowBuilder(tbl *config.Table) (*rowBuilder, error) {
-	result := rowBuilder{}
+	result := rowBuilder{geometryLimit: tbl.GeometryLimit, duplicates: tbl.Duplicates}
 
 	for _, mappingColumn := range tbl.Columns {
 		column := valueBuilder{}
@@ -250,6 +380,11 @@ func (m *Mapping) extraTags(tableType TableType, tags map[Key]bool) {
 			for _, k := range col.Keys {
 				tags[Key(k)] = true
 			}
+			if col.Type == "building_height" {
+				for _, k := range buildingHeightKeys {
+					tags[k] = true
+				}
+			}
 		}
 
 		if t.Filters != nil && t.Filters.ExcludeTags != nil {
@@ -0,0 +1,338 @@
+package mapping
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	osm "github.com/omniscale/go-osm"
+)
+
+func TestIDColumn(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		idColumn string
+		errMatch string
+	}{
+		{name: "default", idColumn: ""},
+		{name: "valid", idColumn: "osm_id"},
+		{name: "wrong type", idColumn: "name", errMatch: "needs to be a column with type id"},
+		{name: "missing", idColumn: "unknown", errMatch: "not found in columns"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New([]byte(`
+    tables:
+      roads:
+        type: linestring
+        id_column: ` + tt.idColumn + `
+        columns:
+        - name: osm_id
+          type: id
+        - name: name
+          key: name
+          type: string
+        mapping:
+          highway: [__any__]
+    `))
+			if tt.errMatch == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMatch) {
+				t.Errorf("expected error with %q, got %v", tt.errMatch, err)
+			}
+		})
+	}
+}
+
+func TestGeneralizedTableSQLFilter(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		options  string
+		errMatch string
+	}{
+		{name: "sql_filter", options: `sql_filter: "highway = 'primary'"`},
+		{name: "sql", options: `sql: {postgis: "SELECT * FROM {source}"}`},
+		{
+			name:     "sql and sql_filter",
+			options:  `sql: {postgis: "SELECT * FROM {source}"}, sql_filter: "highway = 'primary'"`,
+			errMatch: "sql_filter can not be combined with sql for generalized table roads_gen0",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New([]byte(`
+    tables:
+      roads:
+        type: linestring
+        columns:
+        - name: osm_id
+          type: id
+        mapping:
+          highway: [__any__]
+    generalized_tables:
+      roads_gen0: {source: roads, tolerance: 50, ` + tt.options + `}
+    `))
+			if tt.errMatch == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMatch) {
+				t.Errorf("expected error with %q, got %v", tt.errMatch, err)
+			}
+		})
+	}
+}
+
+func TestUnsupportedColumnTypes(t *testing.T) {
+	mappingYAML := `
+    tables:
+      roads:
+        type: linestring
+        columns:
+        - name: osm_id
+          type: id
+        - name: name
+          key: name
+          type: unknown_string
+        - name: ref
+          key: ref
+          type: unknown_ref
+        mapping:
+          highway: [__any__]
+    `
+	_, err := New([]byte(mappingYAML))
+	if err == nil {
+		t.Fatal("expected error for unsupported types")
+	}
+	for _, col := range []string{"name of table roads (unknown_string)", "ref of table roads (unknown_ref)"} {
+		if !strings.Contains(err.Error(), col) {
+			t.Errorf("expected %q in error, got %v", col, err)
+		}
+	}
+
+	m, err := New([]byte("\n    lenient_column_types: true" + mappingYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range m.Conf.Tables["roads"].Columns[1:] {
+		if c.Type != "string" {
+			t.Errorf("expected string type for %s, got %s", c.Name, c.Type)
+		}
+	}
+}
+
+func TestGeometryLimit(t *testing.T) {
+	m, err := New([]byte(`
+    tables:
+      roads:
+        type: linestring
+        geometry_limit:
+          max_vertices: 1000
+          skip: true
+        columns:
+        - name: osm_id
+          type: id
+        mapping:
+          highway: [__any__]
+    `))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := m.Conf.Tables["roads"].GeometryLimit
+	if l == nil || l.MaxVertices != 1000 || l.MaxBytes != 0 || !l.Skip {
+		t.Errorf("unexpected geometry_limit %#v", l)
+	}
+	matches := m.LineStringMatcher.MatchWay(&osm.Way{Element: osm.Element{Tags: osm.Tags{"highway": "primary"}}})
+	if len(matches) != 1 {
+		t.Fatalf("expected one match, got %#v", matches)
+	}
+	for _, match := range matches {
+		if match.GeometryLimit() != l {
+			t.Errorf("expected geometry_limit for match %#v", match)
+		}
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	for _, tt := range []struct {
+		duplicates string
+		rows       []string
+	}{
+		{"", []string{"primary", "rail"}},
+		{"first", []string{"rail"}},
+		{"merge", []string{"rail;primary"}},
+	} {
+		t.Run(tt.duplicates, func(t *testing.T) {
+			m, err := New([]byte(`
+    tables:
+      transport:
+        type: linestring
+        duplicates: ` + tt.duplicates + `
+        columns:
+        - name: type
+          type: mapping_value
+        mappings:
+          rail:
+            mapping:
+              railway: [__any__]
+          roads:
+            mapping:
+              highway: [__any__]
+    `))
+			if err != nil {
+				t.Fatal(err)
+			}
+			elem := osm.Element{Tags: osm.Tags{"railway": "rail", "highway": "primary"}}
+			var rows []string
+			for _, match := range m.LineStringMatcher.MatchWay(&osm.Way{Element: elem}) {
+				rows = append(rows, match.Row(&elem, nil)[0].(string))
+			}
+			// matches without duplicates option are unordered
+			sort.Strings(rows)
+			if !reflect.DeepEqual(rows, tt.rows) {
+				t.Errorf("expected rows %v, got %v", tt.rows, rows)
+			}
+		})
+	}
+}
+
+func TestBuildingHeightTags(t *testing.T) {
+	m, err := New([]byte(`
+    tables:
+      buildings:
+        type: polygon
+        columns:
+        - name: height
+          type: building_height
+        mapping:
+          building: [__any__]
+    `))
+	if err != nil {
+		t.Fatal(err)
+	}
+	elem := osm.Element{Tags: osm.Tags{
+		"building":        "yes",
+		"building:levels": "3",
+		"roof:levels":     "1",
+		"name":            "Town Hall",
+	}}
+	m.WayTagFilter().Filter(&elem.Tags)
+	if _, ok := elem.Tags["name"]; ok {
+		t.Error("expected name to be filtered", elem.Tags)
+	}
+
+	matches := m.PolygonMatcher.MatchWay(&osm.Way{Element: elem, Refs: []int64{1, 2, 3, 1}})
+	if len(matches) != 1 {
+		t.Fatal("expected one match, got", matches)
+	}
+	if h := matches[0].Row(&elem, nil)[0]; h != float32(12) {
+		t.Errorf("expected height 12, got %v (tags %v)", h, elem.Tags)
+	}
+}
+
+func TestSubset(t *testing.T) {
+	m, err := New([]byte(`
+    tables:
+      roads:
+        type: linestring
+        columns:
+        - name: osm_id
+          type: id
+        mapping:
+          highway: [__any__]
+      landuse:
+        type: polygon
+        columns:
+        - name: osm_id
+          type: id
+        mapping:
+          landuse: [__any__]
+    generalized_tables:
+      roads_gen1:
+        source: roads
+        tolerance: 50
+      roads_gen0:
+        source: roads_gen1
+        tolerance: 200
+      landuse_gen1:
+        source: landuse
+        tolerance: 50
+    `))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subset, err := m.Subset([]string{"roads"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subset.Conf.Tables) != 1 || subset.Conf.Tables["roads"] == nil {
+		t.Errorf("unexpected tables %v", subset.Conf.Tables)
+	}
+	if len(subset.Conf.GeneralizedTables) != 2 || subset.Conf.GeneralizedTables["roads_gen0"] == nil {
+		t.Errorf("unexpected generalized tables %v", subset.Conf.GeneralizedTables)
+	}
+	if matches := subset.PolygonMatcher.MatchWay(&osm.Way{Element: osm.Element{Tags: osm.Tags{"landuse": "forest"}}, Nodes: make([]osm.Node, 4)}); len(matches) != 0 {
+		t.Errorf("unexpected matches %v", matches)
+	}
+	if len(m.Conf.Tables) != 2 || len(m.Conf.GeneralizedTables) != 3 {
+		t.Error("full mapping modified")
+	}
+
+	tags := osm.Tags{"landuse": "forest"}
+	subset.WayTagFilter().Filter(&tags)
+	if len(tags) != 0 {
+		t.Errorf("expected filtered tags, got %v", tags)
+	}
+
+	if _, err := m.Subset([]string{"unknown"}); err == nil {
+		t.Error("expected error for unknown table")
+	}
+}
+
+func TestAssertions(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		assertions string
+		errMatch   string
+	}{
+		{name: "valid", assertions: "{min_rows: 100, max_null_geometry: 0.01, required_values: {type: [motorway]}}"},
+		{name: "negative rows", assertions: "{min_rows: -1}", errMatch: "min_rows"},
+		{name: "fraction", assertions: "{max_null_geometry: 2}", errMatch: "between 0 and 1"},
+		{name: "unknown column", assertions: "{required_values: {name: [foo]}}", errMatch: "column name of required_values not found"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := New([]byte(`
+    tables:
+      roads:
+        type: linestring
+        assertions: ` + tt.assertions + `
+        columns:
+        - name: osm_id
+          type: id
+        - name: type
+          type: mapping_value
+        mapping:
+          highway: [__any__]
+    `))
+			if tt.errMatch == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				a := m.Conf.Tables["roads"].Assertions
+				if a.MinRows != 100 || a.MaxNullGeometry == nil || *a.MaxNullGeometry != 0.01 || a.RequiredValues["type"][0] != "motorway" {
+					t.Errorf("unexpected assertions %#v", a)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMatch) {
+				t.Errorf("expected error with %q, got %v", tt.errMatch, err)
+			}
+		})
+	}
+}
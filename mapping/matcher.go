@@ -1,8 +1,11 @@
 package mapping
 
 import (
+	"sort"
+
 	osm "github.com/omniscale/go-osm"
 	"github.com/omniscale/imposm3/geom"
+	"github.com/omniscale/imposm3/mapping/config"
 )
 
 func (m *Mapping) pointMatcher() (NodeMatcher, error) {
@@ -118,6 +121,14 @@ func (m *Match) Row(elem *osm.Element, geom *geom.Geometry) []interface{} {
 	return m.builder.MakeRow(elem, geom, *m)
 }
 
+// GeometryLimit returns the geometry limit of the table, or nil.
+func (m *Match) GeometryLimit() *config.GeometryLimit {
+	if m.builder == nil {
+		return nil
+	}
+	return m.builder.geometryLimit
+}
+
 func (m *Match) MemberRow(rel *osm.Relation, member *osm.Member, memberIndex int, geom *geom.Geometry) []interface{} {
 	return m.builder.MakeMemberRow(rel, member, memberIndex, geom, *m)
 }
@@ -201,7 +212,7 @@ func (tm *tagMatcher) match(tags osm.Tags, closed bool, relation bool) []Match {
 			}
 		}
 	}
-	var matches []Match
+	var matched []orderedMatch
 	for t, match := range tables {
 		filters, ok := tm.filters[t.Name]
 		filteredOut := false
@@ -226,8 +237,46 @@ func (tm *tagMatcher) match(tags osm.Tags, closed bool, relation bool) []Match {
 		}
 
 		if !filteredOut {
-			matches = append(matches, match.Match)
+			matched = append(matched, match)
+		}
+	}
+	return removeDuplicates(matched)
+}
+
+// removeDuplicates returns all matches, but only the first match of each
+// table with the duplicates option. Other matches of these tables are
+// dropped, or merged into the first.
+func removeDuplicates(matched []orderedMatch) []Match {
+	sorted := false
+	for _, m := range matched {
+		if m.builder != nil && m.builder.duplicates != "" {
+			sort.SliceStable(matched, func(i, j int) bool {
+				return matched[i].order < matched[j].order
+			})
+			sorted = true
+			break
+		}
+	}
+
+	var matches []Match
+	var first map[string]int
+	for _, m := range matched {
+		if sorted && m.builder != nil && m.builder.duplicates != "" {
+			if first == nil {
+				first = make(map[string]int)
+			}
+			if idx, ok := first[m.Table.Name]; ok {
+				if m.builder.duplicates == "merge" {
+					// copy the builder, as it is shared by all matches of the table
+					merged := *matches[idx].builder
+					merged.merged = append(append([]Match(nil), merged.merged...), m.Match)
+					matches[idx].builder = &merged
+				}
+				continue
+			}
+			first[m.Table.Name] = len(matches)
 		}
+		matches = append(matches, m.Match)
 	}
 	return matches
 }
@@ -261,7 +310,11 @@ func (v *valueBuilder) MemberValue(rel *osm.Relation, member *osm.Member, member
 }
 
 type rowBuilder struct {
-	columns []valueBuilder
+	columns       []valueBuilder
+	geometryLimit *config.GeometryLimit
+	duplicates    string
+	// merged are the other matches of the same element for duplicates: merge
+	merged []Match
 }
 
 func (r *rowBuilder) MakeRow(elem *osm.Element, geom *geom.Geometry, match Match) []interface{} {
@@ -269,6 +322,9 @@ func (r *rowBuilder) MakeRow(elem *osm.Element, geom *geom.Geometry, match Match
 	for _, column := range r.columns {
 		row = append(row, column.Value(elem, geom, match))
 	}
+	for _, other := range r.merged {
+		mergeRow(row, other.builder.MakeRow(elem, geom, other))
+	}
 	return row
 }
 
@@ -277,5 +333,26 @@ func (r *rowBuilder) MakeMemberRow(rel *osm.Relation, member *osm.Member, member
 	for _, column := range r.columns {
 		row = append(row, column.MemberValue(rel, member, memberIndex, geom, match))
 	}
+	for _, other := range r.merged {
+		mergeRow(row, other.builder.MakeMemberRow(rel, member, memberIndex, geom, other))
+	}
 	return row
 }
+
+// mergeRow sets all empty values of row to the values of other. Different
+// strings are joined with a semicolon, like multiple values of OSM tags.
+func mergeRow(row, other []interface{}) {
+	for i, v := range row {
+		if v == nil || v == "" {
+			row[i] = other[i]
+			continue
+		}
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		if o, ok := other[i].(string); ok && o != "" && o != s {
+			row[i] = s + ";" + o
+		}
+	}
+}
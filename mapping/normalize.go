@@ -0,0 +1,85 @@
+package mapping
+
+import (
+	"strings"
+	"unicode"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/mapping/config"
+)
+
+// normalizeTags returns a TagFilterer that normalizes the tags before they
+// are passed to filter, if tags.normalize is configured.
+func (m *Mapping) normalizeTags(filter TagFilterer) TagFilterer {
+	if m.Conf.Tags.Normalize == nil {
+		return filter
+	}
+	return &normalizeFilter{conf: *m.Conf.Tags.Normalize, filter: filter}
+}
+
+type normalizeFilter struct {
+	conf   config.TagNormalization
+	filter TagFilterer
+}
+
+func (f *normalizeFilter) Filter(tags *osm.Tags) {
+	if tags == nil {
+		return
+	}
+	f.normalize(tags)
+	f.filter.Filter(tags)
+}
+
+// normalize replaces tags with the normalized tags, if any tag changed.
+// Keys that are equal after the normalization are collapsed into a single
+// tag. The tag with the already normalized key is kept, otherwise the
+// tag with the lowest original key.
+func (f *normalizeFilter) normalize(tags *osm.Tags) {
+	changed := false
+	normalized := make(osm.Tags, len(*tags))
+	origKeys := make(map[string]string, len(*tags))
+	for k, v := range *tags {
+		nk, nv := f.key(k), f.value(v)
+		if nk != k || nv != v {
+			changed = true
+		}
+		if nk == "" || (f.conf.MaxLength > 0 && (len(nk) > f.conf.MaxLength || len(nv) > f.conf.MaxLength)) {
+			changed = true
+			continue
+		}
+		if prev, ok := origKeys[nk]; ok {
+			changed = true
+			if prev == nk || (k != nk && prev < k) {
+				continue
+			}
+		}
+		origKeys[nk] = k
+		normalized[nk] = nv
+	}
+	if changed {
+		*tags = normalized
+	}
+}
+
+func (f *normalizeFilter) key(k string) string {
+	k = f.value(k)
+	if f.conf.LowercaseKeys {
+		k = strings.ToLower(k)
+	}
+	return k
+}
+
+func (f *normalizeFilter) value(v string) string {
+	if f.conf.StripControl {
+		v = strings.Map(func(r rune) rune {
+			if unicode.IsControl(r) {
+				return -1
+			}
+			return r
+		}, v)
+	}
+	if f.conf.TrimSpace {
+		v = strings.TrimSpace(v)
+	}
+	return v
+}
@@ -0,0 +1,71 @@
+package mapping
+
+import (
+	"reflect"
+	"testing"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/mapping/config"
+)
+
+type keepAllFilter struct{}
+
+func (keepAllFilter) Filter(tags *osm.Tags) {}
+
+func TestNormalizeTags(t *testing.T) {
+	f := &normalizeFilter{
+		conf: config.TagNormalization{
+			LowercaseKeys: true,
+			TrimSpace:     true,
+			StripControl:  true,
+			MaxLength:     10,
+		},
+		filter: keepAllFilter{},
+	}
+	for _, tt := range []struct {
+		name string
+		tags osm.Tags
+		want osm.Tags
+	}{
+		{"unchanged", osm.Tags{"highway": "primary"}, osm.Tags{"highway": "primary"}},
+		{"lowercase", osm.Tags{"Highway": "Primary"}, osm.Tags{"highway": "Primary"}},
+		{"trim", osm.Tags{" name": " Main St "}, osm.Tags{"name": "Main St"}},
+		{"control", osm.Tags{"name": "Main\nSt\t"}, osm.Tags{"name": "MainSt"}},
+		{"too long", osm.Tags{"name": "Main Street Long", "highway": "primary"}, osm.Tags{"highway": "primary"}},
+		{"empty key", osm.Tags{" ": "empty", "highway": "primary"}, osm.Tags{"highway": "primary"}},
+		{"collapse normalized", osm.Tags{"Name": "a", "name": "b", "NAME ": "c"}, osm.Tags{"name": "b"}},
+		{"collapse lowest", osm.Tags{"Name": "a", "NAME": "b"}, osm.Tags{"name": "b"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tags := tt.tags
+			f.Filter(&tags)
+			if !reflect.DeepEqual(tags, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, tags)
+			}
+		})
+	}
+}
+
+func TestNormalizeTagsBeforeFilter(t *testing.T) {
+	m, err := New([]byte(`
+    tags:
+      normalize:
+        lowercase_keys: true
+    tables:
+      roads:
+        type: linestring
+        columns:
+        - name: osm_id
+          type: id
+        mapping:
+          highway: [__any__]
+    `))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags := osm.Tags{"Highway": "primary", "Foo": "bar"}
+	m.WayTagFilter().Filter(&tags)
+	if !reflect.DeepEqual(tags, osm.Tags{"highway": "primary"}) {
+		t.Errorf("unexpected tags %v", tags)
+	}
+}
@@ -0,0 +1,181 @@
+package reader
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/go-osm/parser/pbf"
+	"github.com/omniscale/imposm3/log"
+	"github.com/omniscale/imposm3/mapping"
+	"github.com/pkg/errors"
+)
+
+// idSet is a sparse bitset of element IDs. Node IDs of a single area are
+// mostly consecutive, so this requires less memory than a map of IDs.
+type idSet map[int64]uint64
+
+func (s idSet) add(id int64) {
+	s[id>>6] |= 1 << uint64(id&63)
+}
+
+func (s idSet) has(id int64) bool {
+	return s[id>>6]&(1<<uint64(id&63)) != 0
+}
+
+// Selection contains all elements that can match the mappings, and all
+// elements that are referenced by them: member relations, ways and nodes of
+// matching relations, and the nodes of all selected ways.
+type Selection struct {
+	relations idSet
+	ways      idSet
+	nodes     idSet
+}
+
+func (s *Selection) hasRelation(id int64) bool { return s == nil || s.relations.has(id) }
+func (s *Selection) hasWay(id int64) bool      { return s == nil || s.ways.has(id) }
+func (s *Selection) hasNode(id int64) bool     { return s == nil || s.nodes.has(id) }
+
+// Prefilter reads the relations and ways of the PBF file in two passes and
+// returns the Selection of all elements that need to be cached for the
+// mappings. Nodes are only selected by reference, tagged nodes are matched
+// while reading.
+func Prefilter(filename string, tagmappings []*mapping.Mapping) (*Selection, error) {
+	sel := &Selection{
+		relations: make(idSet),
+		ways:      make(idSet),
+		nodes:     make(idSet),
+	}
+
+	step := log.Step("Prefiltering relations")
+	relFilter := tagFilter(tagmappings, (*mapping.Mapping).RelationTagFilter)
+	// member relations of selected relations, selected in the next pass
+	members := make(idSet)
+	selectRelation := func(rel *osm.Relation) {
+		sel.relations.add(rel.ID)
+		for _, m := range rel.Members {
+			switch m.Type {
+			case osm.WayMember:
+				sel.ways.add(m.ID)
+			case osm.NodeMember:
+				sel.nodes.add(m.ID)
+			case osm.RelationMember:
+				if !sel.relations.has(m.ID) {
+					members.add(m.ID)
+				}
+			}
+		}
+	}
+	err := parseElements(filename, pbf.Config{Relations: make(chan []osm.Relation, 4)}, func(conf pbf.Config) {
+		for rels := range conf.Relations {
+			for i := range rels {
+				relFilter.Filter(&rels[i].Tags)
+				if !matchRelation(tagmappings, &rels[i]) {
+					continue
+				}
+				selectRelation(&rels[i])
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Member relations are required for relation_member tables and nested
+	// relations. Repeat until all (nested) member relations are selected.
+	for len(members) > 0 {
+		wanted := members
+		members = make(idSet)
+		err := parseElements(filename, pbf.Config{Relations: make(chan []osm.Relation, 4)}, func(conf pbf.Config) {
+			for rels := range conf.Relations {
+				for i := range rels {
+					if wanted.has(rels[i].ID) && !sel.relations.has(rels[i].ID) {
+						selectRelation(&rels[i])
+					}
+				}
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	step()
+
+	step = log.Step("Prefiltering ways")
+	wayFilter := tagFilter(tagmappings, (*mapping.Mapping).WayTagFilter)
+	err = parseElements(filename, pbf.Config{Ways: make(chan []osm.Way, 4)}, func(conf pbf.Config) {
+		for ws := range conf.Ways {
+			for i := range ws {
+				if !sel.ways.has(ws[i].ID) {
+					wayFilter.Filter(&ws[i].Tags)
+					if !matchWay(tagmappings, &ws[i]) {
+						continue
+					}
+					sel.ways.add(ws[i].ID)
+				}
+				for _, ref := range ws[i].Refs {
+					sel.nodes.add(ref)
+				}
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	step()
+	return sel, nil
+}
+
+// parseElements parses filename with conf and calls consume with conf in a
+// single goroutine, as the Selection is not synchronized.
+func parseElements(filename string, conf pbf.Config, consume func(pbf.Config)) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return errors.Wrap(err, "opening PBF file")
+	}
+	defer f.Close()
+
+	parser := pbf.New(f, conf)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		consume(conf)
+		wg.Done()
+	}()
+	err = parser.Parse(context.Background())
+	wg.Wait()
+	if err != nil {
+		return errors.Wrap(err, "parsing PBF")
+	}
+	return nil
+}
+
+func matchRelation(tagmappings []*mapping.Mapping, rel *osm.Relation) bool {
+	for _, m := range tagmappings {
+		if len(m.PolygonMatcher.MatchRelation(rel)) > 0 ||
+			len(m.RelationMatcher.MatchRelation(rel)) > 0 ||
+			len(m.RelationMemberMatcher.MatchRelation(rel)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func matchWay(tagmappings []*mapping.Mapping, way *osm.Way) bool {
+	for _, m := range tagmappings {
+		if len(m.LineStringMatcher.MatchWay(way)) > 0 || len(m.PolygonMatcher.MatchWay(way)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func matchNode(tagmappings []*mapping.Mapping, node *osm.Node) bool {
+	for _, m := range tagmappings {
+		if len(m.PointMatcher.MatchNode(node)) > 0 {
+			return true
+		}
+	}
+	return false
+}
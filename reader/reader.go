@@ -45,6 +45,25 @@ func init() {
 	}
 }
 
+// SetProcs distributes cpus between the PBF parser and the cache writers.
+// The IMPOSM_READ_PROCS environment variable takes precedence.
+func SetProcs(cpus int) {
+	if cpus <= 0 || os.Getenv("IMPOSM_READ_PROCS") != "" {
+		return
+	}
+	nParser, nRels, nWays, nNodes, nCoords = readersForCpus(cpus)
+}
+
+// tagFilter returns a filter that keeps all tags required by any of the
+// mappings.
+func tagFilter(tagmappings []*mapping.Mapping, filter func(*mapping.Mapping) mapping.TagFilterer) mapping.TagFilterer {
+	filters := make([]mapping.TagFilterer, 0, len(tagmappings))
+	for _, m := range tagmappings {
+		filters = append(filters, filter(m))
+	}
+	return mapping.CombineTagFilters(filters...)
+}
+
 func readersForCpus(cpus int) (int64, int64, int64, int64, int64) {
 	cpuf := float64(cpus)
 	return int64(math.Ceil(cpuf * 0.75)), int64(math.Ceil(cpuf * 0.25)), int64(math.Ceil(cpuf * 0.25)), int64(math.Ceil(cpuf * 0.25)), int64(math.Ceil(cpuf * 0.25))
@@ -54,8 +73,9 @@ func ReadPbf(
 	filename string,
 	cache *osmcache.OSMCache,
 	progress *stats.Statistics,
-	tagmapping *mapping.Mapping,
+	tagmappings []*mapping.Mapping,
 	limiter *limit.Limiter,
+	selection *Selection,
 ) error {
 	nodes := make(chan []osm.Node, 4)
 	coords := make(chan []osm.Node, 4)
@@ -121,7 +141,7 @@ func ReadPbf(
 		go func() {
 			var skip, hit int
 
-			m := tagmapping.WayTagFilter()
+			m := tagFilter(tagmappings, (*mapping.Mapping).WayTagFilter)
 			for ws := range ways {
 				if ws == nil {
 					waysSync.Done()
@@ -132,6 +152,10 @@ func ReadPbf(
 					continue
 				}
 				for i := range ws {
+					if !selection.hasWay(ws[i].ID) {
+						ws[i].ID = osmcache.SKIP
+						continue
+					}
 					m.Filter(&ws[i].Tags)
 					if withLimiter {
 						cached, err := cache.Coords.FirstRefIsCached(ws[i].Refs)
@@ -163,10 +187,14 @@ func ReadPbf(
 		go func() {
 			var skip, hit int
 
-			m := tagmapping.RelationTagFilter()
+			m := tagFilter(tagmappings, (*mapping.Mapping).RelationTagFilter)
 			for rels := range relations {
 				numWithTags := 0
 				for i := range rels {
+					if !selection.hasRelation(rels[i].ID) {
+						rels[i].ID = osmcache.SKIP
+						continue
+					}
 					m.Filter(&rels[i].Tags)
 					if len(rels[i].Tags) > 0 {
 						numWithTags++
@@ -209,11 +237,21 @@ func ReadPbf(
 					coordsSync.Wait()
 					continue
 				}
-				if skipCoords {
+				if skipCoords || cache.ExternalCoords() {
 					continue
 				}
+				if selection != nil {
+					for i := range nds {
+						if !selection.hasNode(nds[i].ID) {
+							nds[i].ID = osmcache.SKIP
+						}
+					}
+				}
 				if withLimiter {
 					for i := range nds {
+						if nds[i].ID == osmcache.SKIP {
+							continue
+						}
 						if !limiter.IntersectsBuffer(g, nds[i].Long, nds[i].Lat) {
 							skip++
 							nds[i].ID = osmcache.SKIP
@@ -235,7 +273,7 @@ func ReadPbf(
 		go func() {
 			g := geos.NewGeos()
 			defer g.Finish()
-			m := tagmapping.NodeTagFilter()
+			m := tagFilter(tagmappings, (*mapping.Mapping).NodeTagFilter)
 			for nds := range nodes {
 				if nds == nil {
 					coordsSync.Done()
@@ -248,6 +286,10 @@ func ReadPbf(
 				numWithTags := 0
 				for i := range nds {
 					m.Filter(&nds[i].Tags)
+					if selection != nil && !selection.hasNode(nds[i].ID) && !matchNode(tagmappings, &nds[i]) {
+						nds[i].ID = osmcache.SKIP
+						continue
+					}
 					if len(nds[i].Tags) > 0 {
 						numWithTags++
 					}
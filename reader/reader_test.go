@@ -2,6 +2,10 @@ package reader
 
 import (
 	"testing"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/go-osm/parser/pbf"
+	"github.com/omniscale/imposm3/mapping"
 )
 
 func TestReaderCpus(t *testing.T) {
@@ -30,3 +34,104 @@ func TestReaderCpus(t *testing.T) {
 		t.Fatal(p, r, w, n, c)
 	}
 }
+
+func TestIDSet(t *testing.T) {
+	s := make(idSet)
+	for _, id := range []int64{0, 1, 63, 64, 4711, 1 << 40} {
+		s.add(id)
+	}
+	for _, id := range []int64{0, 1, 63, 64, 4711, 1 << 40} {
+		if !s.has(id) {
+			t.Errorf("expected %d in set", id)
+		}
+	}
+	for _, id := range []int64{2, 62, 65, 4710, 1<<40 + 1} {
+		if s.has(id) {
+			t.Errorf("unexpected %d in set", id)
+		}
+	}
+	if len(s) != 4 {
+		t.Errorf("expected 4 words, got %d", len(s))
+	}
+}
+
+func TestPrefilter(t *testing.T) {
+	m, err := mapping.New([]byte(`
+    tables:
+      roads:
+        type: linestring
+        columns:
+        - name: osm_id
+          type: id
+        mapping:
+          highway: [motorway, primary]
+    `))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sel, err := Prefilter("../vendor/github.com/omniscale/go-osm/parser/pbf/monaco-20150428.osm.pbf", []*mapping.Mapping{m})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sel.ways) == 0 || len(sel.nodes) == 0 {
+		t.Errorf("expected selected ways and nodes, got %d/%d", len(sel.ways), len(sel.nodes))
+	}
+	if len(sel.relations) != 0 {
+		t.Errorf("expected no selected relations, got %d", len(sel.relations))
+	}
+
+	var nilSel *Selection
+	if !nilSel.hasWay(1) || !nilSel.hasNode(1) || !nilSel.hasRelation(1) {
+		t.Error("expected all elements for nil selection")
+	}
+}
+
+func TestPrefilterMemberRelations(t *testing.T) {
+	m, err := mapping.New([]byte(`
+    tables:
+      route_master_members:
+        type: relation_member
+        columns:
+        - name: osm_id
+          type: id
+        - name: member
+          type: member_id
+        mapping:
+          type: [route_master]
+    `))
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := "../vendor/github.com/omniscale/go-osm/parser/pbf/monaco-20150428.osm.pbf"
+	sel, err := Prefilter(filename, []*mapping.Mapping{m})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var masters, members int
+	err = parseElements(filename, pbf.Config{Relations: make(chan []osm.Relation, 4)}, func(conf pbf.Config) {
+		for rels := range conf.Relations {
+			for _, rel := range rels {
+				if rel.Tags["type"] != "route_master" {
+					continue
+				}
+				masters++
+				for _, member := range rel.Members {
+					if member.Type != osm.RelationMember {
+						continue
+					}
+					members++
+					if !sel.hasRelation(member.ID) {
+						t.Errorf("member relation %d of %d not selected", member.ID, rel.ID)
+					}
+				}
+			}
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if masters == 0 || members == 0 {
+		t.Fatalf("expected route_master relations with members in test file, got %d/%d", masters, members)
+	}
+}
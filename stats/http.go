@@ -1,14 +1,72 @@
 package stats
 
 import (
+	"expvar"
 	"net/http"
 	_ "net/http/pprof"
+	"sync"
+	"time"
 
 	"github.com/omniscale/imposm3/log"
 )
 
+// StartHTTPPProf starts an HTTP server with the net/http/pprof profiles
+// (/debug/pprof/) and the expvar counters (/debug/vars).
 func StartHTTPPProf(bind string) {
 	go func() {
 		log.Println(http.ListenAndServe(bind, nil))
 	}()
 }
+
+var (
+	activeMu      sync.Mutex
+	activeCounter *Counter
+)
+
+// setActiveCounter sets the counter that is published as the "imposm"
+// expvar. Only the latest counter is published, as the read and write
+// phase of an import use separate counters.
+func setActiveCounter(c *Counter) {
+	activeMu.Lock()
+	activeCounter = c
+	activeMu.Unlock()
+}
+
+type expvarCount struct {
+	Current int64 `json:"current"`
+	Total   int64 `json:"total,omitempty"`
+}
+
+type expvarCounts struct {
+	Started   time.Time   `json:"started"`
+	Duration  float64     `json:"duration_seconds"`
+	Coords    expvarCount `json:"coords"`
+	Nodes     expvarCount `json:"nodes"`
+	Ways      expvarCount `json:"ways"`
+	Relations expvarCount `json:"relations"`
+}
+
+func expvarCountOf(r *RpsCounter) expvarCount {
+	return expvarCount{Current: r.Value(), Total: r.total}
+}
+
+func init() {
+	expvar.Publish("imposm", expvar.Func(func() interface{} {
+		activeMu.Lock()
+		c := activeCounter
+		activeMu.Unlock()
+		if c == nil {
+			return nil
+		}
+		// RPS values are not included, as they are NaN before the first
+		// tick and expvar can not encode them
+		return expvarCounts{
+			Started:   c.start,
+			Duration:  time.Since(c.start).Seconds(),
+			Coords:    expvarCountOf(c.Coords),
+			Nodes:     expvarCountOf(c.Nodes),
+			Ways:      expvarCountOf(c.Ways),
+			Relations: expvarCountOf(c.Relations),
+		}
+	}))
+}
@@ -85,6 +85,7 @@ func NewStatsReporter() *Statistics {
 	s := Statistics{}
 	s.counter = NewCounter()
 	s.done = make(chan bool)
+	setActiveCounter(s.counter)
 
 	go s.loop()
 	return &s
@@ -98,6 +99,7 @@ func NewStatsReporterWithEstimate(counts *ElementCounts) *Statistics {
 		s.counter = NewCounter()
 	}
 	s.done = make(chan bool)
+	setActiveCounter(s.counter)
 
 	go s.loop()
 	return &s
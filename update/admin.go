@@ -0,0 +1,163 @@
+package update
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/omniscale/imposm3/log"
+	"github.com/omniscale/imposm3/mapping"
+)
+
+// adminServer provides an HTTP API to control the run mode:
+//
+//	GET  /status          current runStatus as JSON
+//	POST /pause           stop importing new diffs after the current one
+//	POST /resume          continue importing diffs
+//	POST /trigger         import the next diff or retry a failed import without waiting
+//	POST /reload-mapping  check the mapping file that is used for the next diff
+//
+// All requests require an "Authorization: Bearer <token>" header.
+type adminServer struct {
+	token       string
+	mappingFile string
+	status      *statusFile
+
+	mu     sync.Mutex
+	paused bool
+	// wake is signaled when the run loop should re-check its state
+	// (resume, trigger)
+	wake chan struct{}
+	// trigger is signaled when the run loop should check for the next diff
+	// without waiting for the downloader
+	trigger chan struct{}
+}
+
+func newAdminServer(token, mappingFile string, status *statusFile) *adminServer {
+	return &adminServer{
+		token:       token,
+		mappingFile: mappingFile,
+		status:      status,
+		wake:        make(chan struct{}, 1),
+		trigger:     make(chan struct{}, 1),
+	}
+}
+
+// Start serves the admin API on bind in the background.
+func (a *adminServer) Start(bind string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", a.handle("GET", a.handleStatus))
+	mux.HandleFunc("/pause", a.handle("POST", a.handlePause))
+	mux.HandleFunc("/resume", a.handle("POST", a.handleResume))
+	mux.HandleFunc("/trigger", a.handle("POST", a.handleTrigger))
+	mux.HandleFunc("/reload-mapping", a.handle("POST", a.handleReloadMapping))
+
+	log.Printf("[info] Starting admin API on %s", bind)
+	go func() {
+		log.Println("[error] Admin API:", http.ListenAndServe(bind, mux))
+	}()
+}
+
+// Paused returns whether the import of new diffs is paused.
+func (a *adminServer) Paused() bool {
+	if a == nil {
+		return false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.paused
+}
+
+// Wake returns a channel that receives a value after resume and trigger
+// requests. It returns nil (blocks forever) for a nil *adminServer.
+func (a *adminServer) Wake() <-chan struct{} {
+	if a == nil {
+		return nil
+	}
+	return a.wake
+}
+
+// Trigger returns a channel that receives a value after trigger requests.
+// It returns nil (blocks forever) for a nil *adminServer.
+func (a *adminServer) Trigger() <-chan struct{} {
+	if a == nil {
+		return nil
+	}
+	return a.trigger
+}
+
+func (a *adminServer) signal() {
+	notify(a.wake)
+}
+
+func notify(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+func (a *adminServer) handle(method string, f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		token := strings.TrimPrefix(auth, "Bearer ")
+		if auth == token || subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method != method {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		f(w, r)
+	}
+}
+
+func (a *adminServer) writeStatus(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a.status.Status())
+}
+
+func (a *adminServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	a.writeStatus(w)
+}
+
+func (a *adminServer) setPaused(paused bool) {
+	a.mu.Lock()
+	a.paused = paused
+	a.mu.Unlock()
+	a.status.SetPaused(paused)
+}
+
+func (a *adminServer) handlePause(w http.ResponseWriter, r *http.Request) {
+	log.Println("[info] Pausing diff import (admin API)")
+	a.setPaused(true)
+	a.writeStatus(w)
+}
+
+func (a *adminServer) handleResume(w http.ResponseWriter, r *http.Request) {
+	log.Println("[info] Resuming diff import (admin API)")
+	a.setPaused(false)
+	a.signal()
+	a.writeStatus(w)
+}
+
+func (a *adminServer) handleTrigger(w http.ResponseWriter, r *http.Request) {
+	log.Println("[info] Triggering diff import (admin API)")
+	a.signal()
+	notify(a.trigger)
+	a.writeStatus(w)
+}
+
+// handleReloadMapping only checks the mapping, as each diff import reads
+// the mapping file again.
+func (a *adminServer) handleReloadMapping(w http.ResponseWriter, r *http.Request) {
+	if _, err := mapping.FromFile(a.mappingFile); err != nil {
+		http.Error(w, "invalid mapping: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("[info] Mapping %s is valid and used for the next diff (admin API)", a.mappingFile)
+	a.writeStatus(w)
+}
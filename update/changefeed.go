@@ -0,0 +1,199 @@
+package update
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/database"
+	"github.com/omniscale/imposm3/geom"
+	"github.com/omniscale/imposm3/geom/geos"
+	"github.com/omniscale/imposm3/log"
+	"github.com/omniscale/imposm3/mapping"
+)
+
+// change is a single insert or delete of a diff import. A "commit" change
+// with the sequence is sent after all changes of a diff.
+type change struct {
+	Op       string      `json:"op"`
+	Table    string      `json:"table,omitempty"`
+	ID       int64       `json:"id,omitempty"`
+	BBox     *[4]float64 `json:"bbox,omitempty"`
+	Sequence int         `json:"sequence,omitempty"`
+}
+
+// clientBuffer is the number of changes buffered for each client. Changes
+// are dropped for clients that do not read fast enough, as the diff import
+// should not wait for clients.
+const clientBuffer = 4096
+
+// changeFeed streams the changes of all diff imports as server-sent events
+// to all connected clients.
+type changeFeed struct {
+	mu      sync.Mutex
+	clients map[chan []byte]struct{}
+}
+
+func newChangeFeed() *changeFeed {
+	return &changeFeed{clients: make(map[chan []byte]struct{})}
+}
+
+// Start serves the change feed on bind in the background.
+func (f *changeFeed) Start(bind string) {
+	mux := http.NewServeMux()
+	mux.Handle("/changes", f)
+	log.Printf("[info] Starting change feed on %s", bind)
+	go func() {
+		log.Println("[error] Change feed:", http.ListenAndServe(bind, mux))
+	}()
+}
+
+func (f *changeFeed) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	c := make(chan []byte, clientBuffer)
+	f.mu.Lock()
+	f.clients[c] = struct{}{}
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		delete(f.clients, c)
+		f.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg := <-c:
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", msg); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// publish sends all changes to all connected clients.
+func (f *changeFeed) publish(changes []change) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.clients) == 0 {
+		return
+	}
+	dropped := 0
+	for _, ch := range changes {
+		msg, err := json.Marshal(ch)
+		if err != nil {
+			log.Println("[warn] Encoding change:", err)
+			continue
+		}
+		for c := range f.clients {
+			select {
+			case c <- msg:
+			default:
+				dropped++
+			}
+		}
+	}
+	if dropped > 0 {
+		log.Printf("[warn] Dropped %d changes for slow change feed clients", dropped)
+	}
+}
+
+// feedDB records all inserts and deletes of the wrapped database. The
+// changes are only published with Commit, as the diff import can still
+// fail and roll back.
+type feedDB struct {
+	database.Deleter
+	feed *changeFeed
+
+	mu      sync.Mutex
+	changes []change
+}
+
+func newFeedDB(db database.Deleter, feed *changeFeed) *feedDB {
+	return &feedDB{Deleter: db, feed: feed}
+}
+
+func (f *feedDB) record(op string, id int64, g *geom.Geometry, matches []mapping.Match) {
+	var bbox *[4]float64
+	if g != nil && g.Geom != nil {
+		if b := g.Geom.Bounds(); b != geos.NilBounds {
+			bbox = &[4]float64{b.MinX, b.MinY, b.MaxX, b.MaxY}
+		}
+	}
+	f.mu.Lock()
+	for _, m := range matches {
+		f.changes = append(f.changes, change{Op: op, Table: m.Table.Name, ID: id, BBox: bbox})
+	}
+	f.mu.Unlock()
+}
+
+func (f *feedDB) InsertPoint(elem osm.Element, g geom.Geometry, matches []mapping.Match) error {
+	if err := f.Deleter.InsertPoint(elem, g, matches); err != nil {
+		return err
+	}
+	f.record("insert", elem.ID, &g, matches)
+	return nil
+}
+
+func (f *feedDB) InsertLineString(elem osm.Element, g geom.Geometry, matches []mapping.Match) error {
+	if err := f.Deleter.InsertLineString(elem, g, matches); err != nil {
+		return err
+	}
+	f.record("insert", elem.ID, &g, matches)
+	return nil
+}
+
+func (f *feedDB) InsertPolygon(elem osm.Element, g geom.Geometry, matches []mapping.Match) error {
+	if err := f.Deleter.InsertPolygon(elem, g, matches); err != nil {
+		return err
+	}
+	f.record("insert", elem.ID, &g, matches)
+	return nil
+}
+
+func (f *feedDB) InsertRelationMember(rel osm.Relation, m osm.Member, mi int, g geom.Geometry, matches []mapping.Match) error {
+	if err := f.Deleter.InsertRelationMember(rel, m, mi, g, matches); err != nil {
+		return err
+	}
+	f.record("insert", rel.ID, &g, matches)
+	return nil
+}
+
+func (f *feedDB) Delete(id int64, matches []mapping.Match) error {
+	if err := f.Deleter.Delete(id, matches); err != nil {
+		return err
+	}
+	f.record("delete", id, nil, matches)
+	return nil
+}
+
+// ReportError passes errors to the wrapped database, if it implements
+// database.ErrorReporter.
+func (f *feedDB) ReportError(elem osm.Element, matches []mapping.Match, err error) {
+	if reporter, ok := f.Deleter.(database.ErrorReporter); ok {
+		reporter.ReportError(elem, matches, err)
+	}
+}
+
+// Commit publishes all recorded changes, followed by a commit change with
+// the sequence of the diff (0 if unknown).
+func (f *feedDB) Commit(sequence int) {
+	f.mu.Lock()
+	changes := append(f.changes, change{Op: "commit", Sequence: sequence})
+	f.changes = nil
+	f.mu.Unlock()
+	f.feed.publish(changes)
+}
@@ -0,0 +1,89 @@
+package update
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/omniscale/go-osm/replication"
+	"github.com/omniscale/go-osm/state"
+	"github.com/pkg/errors"
+)
+
+var fetchClient = &http.Client{Timeout: 5 * time.Minute}
+
+// fetchSequence downloads the diff and state file of seq from url into
+// diffDir, with the same layout as the replication downloader. This allows
+// the run loop to import the next diff immediately (POST /trigger), while
+// the downloader is still waiting for the next interval. It returns nil if
+// the sequence is not available yet.
+func fetchSequence(diffDir, url string, seq int) (*replication.Sequence, error) {
+	base := seqPath(seq)
+	for _, ext := range []string{".osc.gz", ".state.txt"} {
+		ok, err := fetchFile(url+base+ext, filepath.Join(diffDir, base+ext))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, nil
+		}
+	}
+	stateFile := filepath.Join(diffDir, base+".state.txt")
+	s, err := state.ParseFile(stateFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing state of #%d", seq)
+	}
+	return &replication.Sequence{
+		Sequence:      seq,
+		Filename:      filepath.Join(diffDir, base+".osc.gz"),
+		StateFilename: stateFile,
+		Time:          s.Time,
+	}, nil
+}
+
+// fetchFile downloads url to dest, if dest does not exist. It returns false
+// if url is not found.
+func fetchFile(url, dest string) (bool, error) {
+	if _, err := os.Stat(dest); err == nil {
+		return true, nil
+	}
+	resp, err := fetchClient.Get(url)
+	if err != nil {
+		return false, errors.Wrapf(err, "downloading %s", url)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, errors.Errorf("downloading %s: %s", url, resp.Status)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return false, err
+	}
+	// different suffix than the downloader, as both can fetch the same file
+	tmp := fmt.Sprintf("%s~trigger%d", dest, os.Getpid())
+	f, err := os.Create(tmp)
+	if err != nil {
+		return false, err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return false, errors.Wrapf(err, "downloading %s", url)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+	return true, os.Rename(tmp, dest)
+}
+
+// seqPath returns the path of a sequence, N = AAA*1000000 + BBB*1000 + CCC
+func seqPath(seq int) string {
+	return fmt.Sprintf("%03d/%03d/%03d", seq/1000000, seq/1000%1000, seq%1000)
+}
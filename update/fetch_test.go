@@ -0,0 +1,74 @@
+package update
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFetchSequence(t *testing.T) {
+	files := map[string]string{
+		"/000/001/234.osc.gz":    "diff",
+		"/000/001/234.state.txt": "sequenceNumber=1234\ntimestamp=2019-02-26T09\\:00\\:02Z\n",
+		// state is uploaded after the diff
+		"/000/001/235.osc.gz": "diff",
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(content))
+	}))
+	defer ts.Close()
+
+	tmpdir, err := ioutil.TempDir("", "imposm3_fetch_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	seq, err := fetchSequence(tmpdir, ts.URL+"/", 1234)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq == nil {
+		t.Fatal("#1234 not available")
+	}
+	if seq.Sequence != 1234 || seq.Time.Hour() != 9 {
+		t.Errorf("unexpected sequence %#v", seq)
+	}
+	if seq.Filename != filepath.Join(tmpdir, "000/001/234.osc.gz") {
+		t.Errorf("unexpected filename %s", seq.Filename)
+	}
+	if content, err := ioutil.ReadFile(seq.Filename); err != nil || string(content) != "diff" {
+		t.Errorf("unexpected diff file %q %v", content, err)
+	}
+
+	for _, s := range []int{1235, 1236} {
+		seq, err := fetchSequence(tmpdir, ts.URL+"/", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seq != nil {
+			t.Errorf("#%d should not be available", s)
+		}
+	}
+}
+
+func TestSeqPath(t *testing.T) {
+	for seq, expected := range map[int]string{
+		0:          "000/000/000",
+		1234:       "000/001/234",
+		3456789:    "003/456/789",
+		1003456789: "1003/456/789",
+	} {
+		if p := seqPath(seq); p != expected {
+			t.Errorf("seqPath(%d) = %s, expected %s", seq, p, expected)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package update
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/omniscale/imposm3/database"
+	"github.com/omniscale/imposm3/log"
+)
+
+// importTimeout is the time after which a running diff import is
+// considered hung by /healthz.
+const importTimeout = time.Hour
+
+// healthServer provides unauthenticated endpoints for liveness and
+// readiness probes (e.g. from Kubernetes):
+//
+//	GET /healthz  fails if a diff import is running longer than importTimeout
+//	GET /readyz   fails if the last diff import failed, if the replication
+//	              lag exceeds maxLag or if the database is not reachable
+type healthServer struct {
+	status *statusFile
+	maxLag time.Duration
+	db     database.DB
+}
+
+func newHealthServer(status *statusFile, maxLag time.Duration, db database.DB) *healthServer {
+	return &healthServer{status: status, maxLag: maxLag, db: db}
+}
+
+// Start serves the health endpoints on bind in the background.
+func (h *healthServer) Start(bind string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", h.handleHealth)
+	mux.HandleFunc("/readyz", h.handleReady)
+	log.Printf("[info] Starting health endpoints on %s", bind)
+	go func() {
+		log.Println("[error] Health endpoints:", http.ListenAndServe(bind, mux))
+	}()
+}
+
+func (h *healthServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	st := h.status.Status()
+	var problems []string
+	if st.State == "importing" && time.Since(st.StateSince) > importTimeout {
+		problems = append(problems, fmt.Sprintf("importing #%d since %s", st.Sequence, st.StateSince.Format(time.RFC3339)))
+	}
+	writeHealth(w, problems)
+}
+
+func (h *healthServer) handleReady(w http.ResponseWriter, r *http.Request) {
+	st := h.status.Status()
+	var problems []string
+	if st.State == "retrying" {
+		problems = append(problems, "last diff import failed: "+st.LastError)
+	}
+	if h.maxLag > 0 && st.SequenceTime != nil {
+		if lag := time.Since(*st.SequenceTime); lag > h.maxLag {
+			problems = append(problems, fmt.Sprintf("replication is %s behind", lag.Truncate(time.Second)))
+		}
+	}
+	if pinger, ok := h.db.(database.Pinger); ok {
+		if err := pinger.Ping(); err != nil {
+			problems = append(problems, "database: "+err.Error())
+		}
+	}
+	writeHealth(w, problems)
+}
+
+func writeHealth(w http.ResponseWriter, problems []string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if len(problems) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, strings.Join(problems, "\n"))
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
@@ -0,0 +1,75 @@
+package update
+
+import (
+	"os"
+	"time"
+
+	"github.com/omniscale/imposm3/config"
+	"github.com/omniscale/imposm3/database"
+	"github.com/omniscale/imposm3/log"
+	"github.com/pkg/errors"
+)
+
+// leaderRetryInterval is the interval in which standby processes try to
+// acquire the leader lock.
+const leaderRetryInterval = 15 * time.Second
+
+// leaderLock is held by the one process that imports diffs, if multiple
+// processes run with the same leader_lock.
+type leaderLock struct {
+	db     database.DB
+	locker database.Locker
+}
+
+// waitForLeaderLock blocks till the leader lock with name is acquired. It
+// calls shutdown if a signal is received while waiting.
+func waitForLeaderLock(baseOpts config.Base, name string, sigc <-chan os.Signal, shutdown func()) (*leaderLock, error) {
+	db, err := openProductionDB(baseOpts)
+	if err != nil {
+		return nil, err
+	}
+	locker, ok := db.(database.Locker)
+	if !ok {
+		db.Close()
+		return nil, errors.New("database does not support leader_lock")
+	}
+
+	waiting := false
+	for {
+		locked, err := locker.TryLock(name)
+		if err != nil {
+			log.Printf("[error] %s", err)
+		} else if locked {
+			if waiting {
+				log.Printf("[info] Acquired leader lock %s", name)
+			}
+			return &leaderLock{db: db, locker: locker}, nil
+		} else if !waiting {
+			log.Printf("[info] Leader lock %s is held by another process, waiting", name)
+			waiting = true
+		}
+		select {
+		case <-sigc:
+			db.Close()
+			shutdown()
+		case <-time.After(leaderRetryInterval):
+		}
+	}
+}
+
+// Check returns an error if the lock was lost, e.g. because the connection
+// to the database was interrupted. Another process could have acquired the
+// lock in the meantime.
+func (l *leaderLock) Check() error {
+	if l == nil {
+		return nil
+	}
+	return l.locker.CheckLock()
+}
+
+func (l *leaderLock) Close() {
+	if l == nil {
+		return
+	}
+	l.db.Close()
+}
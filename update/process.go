@@ -12,6 +12,7 @@ import (
 	osm "github.com/omniscale/go-osm"
 	"github.com/omniscale/go-osm/parser/diff"
 	diffstate "github.com/omniscale/go-osm/state"
+	"github.com/omniscale/imposm3/alert"
 	"github.com/omniscale/imposm3/cache"
 	"github.com/omniscale/imposm3/config"
 	"github.com/omniscale/imposm3/database"
@@ -31,6 +32,7 @@ func Diff(baseOpts config.Base, files []string) {
 	if baseOpts.Quiet {
 		log.SetMinLevel(log.LInfo)
 	}
+	alert.SendOnFatal(baseOpts.Alerts)
 
 	var geometryLimiter *limit.Limiter
 	if baseOpts.LimitTo != "" {
@@ -59,6 +61,10 @@ func Diff(baseOpts config.Base, files []string) {
 		log.Fatal("[fatal] Opening diff cache:", err)
 	}
 
+	if err := restoreLastState(baseOpts); err != nil {
+		log.Fatal("[fatal] Restoring last state:", err)
+	}
+
 	var exp expire.Expireor
 
 	if baseOpts.ExpireTilesDir != "" {
@@ -92,6 +98,21 @@ func Update(
 	osmCache *cache.OSMCache,
 	diffCache *cache.DiffCache,
 	force bool,
+) error {
+	return update(baseOpts, oscFile, geometryLimiter, expireor, osmCache, diffCache, force, nil)
+}
+
+// update imports oscFile and publishes all changes to feed, if feed is not
+// nil.
+func update(
+	baseOpts config.Base,
+	oscFile string,
+	geometryLimiter *limit.Limiter,
+	expireor expire.Expireor,
+	osmCache *cache.OSMCache,
+	diffCache *cache.DiffCache,
+	force bool,
+	feed *changeFeed,
 ) error {
 	var state *diffstate.DiffState
 	if strings.HasSuffix(oscFile, ".osc.gz") {
@@ -136,6 +157,15 @@ func Update(
 	if err != nil {
 		return err
 	}
+	// tags are filtered with the full mapping, as the cache is also used
+	// for tables that are not updated
+	filtermapping := tagmapping
+	if len(baseOpts.UpdateTables) > 0 {
+		tagmapping, err = tagmapping.Subset(baseOpts.UpdateTables)
+		if err != nil {
+			return errors.Wrap(err, "selecting update tables")
+		}
+	}
 
 	dbConf := database.Config{
 		ConnectionParams: baseOpts.Connection,
@@ -144,6 +174,12 @@ func Update(
 		ImportSchema:     baseOpts.Schemas.Production,
 		ProductionSchema: baseOpts.Schemas.Production,
 		BackupSchema:     baseOpts.Schemas.Backup,
+		StatsTable:       baseOpts.StatsTable,
+		StateTable:       baseOpts.StateTable,
+		TablePrefix:      baseOpts.TablePrefix,
+		TableSuffix:      baseOpts.TableSuffix,
+		QuarantineDir:    baseOpts.QuarantineDir,
+		MaxErrorRate:     baseOpts.MaxErrorRate,
 	}
 	db, err := database.Open(dbConf, &tagmapping.Conf)
 	if err != nil {
@@ -160,6 +196,11 @@ func Update(
 	if !ok {
 		return errors.New("database not deletable")
 	}
+	var fdb *feedDB
+	if feed != nil {
+		fdb = newFeedDB(delDb, feed)
+		delDb = fdb
+	}
 
 	genDb, ok := db.(database.Generalizer)
 	if ok {
@@ -181,9 +222,9 @@ func Update(
 
 	progress := stats.NewStatsReporter()
 
-	relTagFilter := tagmapping.RelationTagFilter()
-	wayTagFilter := tagmapping.WayTagFilter()
-	nodeTagFilter := tagmapping.NodeTagFilter()
+	relTagFilter := filtermapping.RelationTagFilter()
+	wayTagFilter := filtermapping.WayTagFilter()
+	nodeTagFilter := filtermapping.NodeTagFilter()
 
 	relations := make(chan *osm.Relation)
 	ways := make(chan *osm.Way)
@@ -192,18 +233,21 @@ func Update(
 	relWriter := writer.NewRelationWriter(osmCache, diffCache,
 		tagmapping.Conf.SingleIDSpace,
 		relations,
-		db, progress,
+		delDb, progress,
 		tagmapping.PolygonMatcher,
 		tagmapping.RelationMatcher,
 		tagmapping.RelationMemberMatcher,
 		baseOpts.Srid)
 	relWriter.SetLimiter(geometryLimiter)
+	relWriter.SetMaxRelationMembers(baseOpts.MaxRelationMembers)
+	relWriter.SetRelationMemberDepth(baseOpts.RelationMemberDepth)
+	relWriter.SetHugeRelationMode(writer.HugeRelationMode(baseOpts.RelationHugeMode), baseOpts.RelationTolerance)
 	relWriter.SetExpireor(expireor)
 	relWriter.Start()
 
 	wayWriter := writer.NewWayWriter(osmCache, diffCache,
 		tagmapping.Conf.SingleIDSpace,
-		ways, db,
+		ways, delDb,
 		progress,
 		tagmapping.PolygonMatcher,
 		tagmapping.LineStringMatcher,
@@ -212,7 +256,7 @@ func Update(
 	wayWriter.SetExpireor(expireor)
 	wayWriter.Start()
 
-	nodeWriter := writer.NewNodeWriter(osmCache, nodes, db,
+	nodeWriter := writer.NewNodeWriter(osmCache, nodes, delDb,
 		progress,
 		tagmapping.PointMatcher,
 		baseOpts.Srid)
@@ -426,13 +470,48 @@ func Update(
 	wayWriter.Wait()
 
 	if genDb != nil {
-		genDb.GeneralizeUpdates()
+		if err := genDb.GeneralizeUpdates(); err != nil {
+			return errors.Wrap(err, "updating generalized tables")
+		}
+	}
+
+	if state != nil && lastState != nil {
+		state.URL = lastState.URL
+	}
+	// write the state with the diff, otherwise the diff would be imported
+	// again if only the state fails
+	stateWritten := false
+	if db, ok := db.(database.TxStateStore); ok && state != nil {
+		if err := db.WriteStateTx(state); err != nil {
+			return err
+		}
+		stateWritten = true
 	}
 
 	err = db.End()
 	if err != nil {
 		return err
 	}
+	if err := database.RunFinishHooks(db); err != nil {
+		return err
+	}
+	if db, ok := db.(database.StatsWriter); ok {
+		if err := db.WriteStats("diff"); err != nil {
+			return err
+		}
+	}
+	if db, ok := db.(database.StateStore); ok && state != nil && !stateWritten {
+		if err := db.WriteState(state); err != nil {
+			log.Println("[error] Unable to write state to database:", err)
+		}
+	}
+	if fdb != nil {
+		seq := 0
+		if state != nil {
+			seq = state.Sequence
+		}
+		fdb.Commit(seq)
+	}
 	err = db.Close()
 	if err != nil {
 		return err
@@ -443,9 +522,6 @@ func Update(
 	progress.Stop()
 
 	if state != nil {
-		if lastState != nil {
-			state.URL = lastState.URL
-		}
 		err = diffstate.WriteFile(filepath.Join(baseOpts.DiffDir, LastStateFilename), state)
 		if err != nil {
 			log.Println("[error] Unable to write last state:", err)
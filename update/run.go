@@ -5,22 +5,44 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/omniscale/go-osm/replication/diff"
 	"github.com/omniscale/go-osm/state"
+	"github.com/omniscale/imposm3/alert"
 	"github.com/omniscale/imposm3/cache"
 	"github.com/omniscale/imposm3/config"
+	"github.com/omniscale/imposm3/database"
 	"github.com/omniscale/imposm3/expire"
 	"github.com/omniscale/imposm3/geom/limit"
 	"github.com/omniscale/imposm3/log"
+	"github.com/omniscale/imposm3/mapping"
+	"github.com/pkg/errors"
 )
 
 func Run(baseOpts config.Base) {
 	if baseOpts.Quiet {
 		log.SetMinLevel(log.LInfo)
 	}
+	alerts := alert.SendOnFatal(baseOpts.Alerts)
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+
+	var leader *leaderLock
+	if baseOpts.LeaderLock != "" {
+		var err error
+		leader, err = waitForLeaderLock(baseOpts, baseOpts.LeaderLock, sigc, func() {
+			log.Println("[info] Exiting. (SIGTERM/SIGINT/SIGHUP)")
+			os.Exit(0)
+		})
+		if err != nil {
+			log.Fatal("[fatal] Acquiring leader lock:", err)
+		}
+		defer leader.Close()
+	}
 
 	var geometryLimiter *limit.Limiter
 	if baseOpts.LimitTo != "" {
@@ -37,6 +59,9 @@ func Run(baseOpts config.Base) {
 		step()
 	}
 
+	if err := restoreLastState(baseOpts); err != nil {
+		log.Fatal("[fatal] Restoring last state:", err)
+	}
 	s, err := state.ParseFile(filepath.Join(baseOpts.DiffDir, LastStateFilename))
 	if err != nil {
 		log.Fatal("[fatal] Unable to read last.state.txt:", err)
@@ -50,6 +75,9 @@ func Run(baseOpts config.Base) {
 			"or replication_url in -config")
 	}
 	log.Printf("[info] Starting replication from %s with %s interval", replicationURL, baseOpts.ReplicationInterval)
+	if len(baseOpts.UpdateTables) > 0 {
+		log.Printf("[info] Only updating tables %s", strings.Join(baseOpts.UpdateTables, ", "))
+	}
 
 	downloader := diff.NewDownloader(
 		baseOpts.DiffDir,
@@ -73,9 +101,6 @@ func Run(baseOpts config.Base) {
 	}
 	defer diffCache.Close()
 
-	sigc := make(chan os.Signal, 1)
-	signal.Notify(sigc, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
-
 	var tilelist *expire.TileList
 	var lastTlFlush = time.Now()
 	var tileExpireor expire.Expireor
@@ -84,11 +109,38 @@ func Run(baseOpts config.Base) {
 		tileExpireor = tilelist
 	}
 
+	status := newStatusFile(baseOpts.StatusFile)
+	status.Start()
+
+	var admin *adminServer
+	if baseOpts.AdminHTTP != "" {
+		admin = newAdminServer(baseOpts.AdminToken, baseOpts.MappingFile, status)
+		admin.Start(baseOpts.AdminHTTP)
+	}
+
+	if baseOpts.HealthHTTP != "" {
+		db, err := openProductionDB(baseOpts)
+		if err != nil {
+			log.Fatal("[fatal] Opening database for health checks:", err)
+		}
+		defer db.Close()
+		health := newHealthServer(status, baseOpts.Alerts.ReplicationLag.Duration, db)
+		health.Start(baseOpts.HealthHTTP)
+	}
+
+	var feed *changeFeed
+	if baseOpts.ChangeFeedHTTP != "" {
+		feed = newChangeFeed()
+		feed.Start(baseOpts.ChangeFeedHTTP)
+	}
+
 	shutdown := func() {
 		log.Println("[info] Exiting. (SIGTERM/SIGINT/SIGHUP)")
+		status.Stop()
 		downloader.Stop()
 		osmCache.Close()
 		diffCache.Close()
+		leader.Close()
 		if tilelist != nil {
 			err := tilelist.Flush()
 			if err != nil {
@@ -100,63 +152,167 @@ func Run(baseOpts config.Base) {
 
 	exp := newExpBackoff(2*time.Second, 5*time.Minute)
 
-	for {
-		select {
-		case <-sigc:
-			shutdown()
-		case seq := <-nextSeq:
-			if seq.Error != nil {
-				log.Printf("[error] Downloading #%d: %s", seq.Sequence, seq.Error)
-				continue
+	failedDiffs := 0
+	lastSeqTime := s.Time
+	lagAlerted := false
+	var lagTicker <-chan time.Time
+	maxLag := baseOpts.Alerts.ReplicationLag.Duration
+	if maxLag > 0 {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		lagTicker = ticker.C
+	}
+	checkLag := func() {
+		if maxLag == 0 {
+			return
+		}
+		if lag := time.Since(lastSeqTime); lag > maxLag {
+			if !lagAlerted {
+				alerts.Send(alert.ReplicationLag, fmt.Sprintf("Replication is %s behind (last imported changes till %s)", lag.Truncate(time.Second), lastSeqTime))
+				lagAlerted = true
 			}
-			fname := seq.Filename
-			seqID := seq.Sequence
-			seqTime := seq.Time
-			for {
-				log.Printf("[info] Importing #%d including changes till %s (%s behind)", seqID, seqTime, time.Since(seqTime).Truncate(time.Second))
-				finishedImport := log.Step(fmt.Sprintf("Importing #%d", seqID))
-
-				err := Update(baseOpts, fname, geometryLimiter, tileExpireor, osmCache, diffCache, false)
-
-				osmCache.Coords.Flush()
-				diffCache.Flush()
-
-				if err == nil && tilelist != nil && time.Since(lastTlFlush) > time.Second*30 {
-					// call at most once every 30 seconds to reduce files during the
-					// catch-up phase after the initial import
-					lastTlFlush = time.Now()
-					err := tilelist.Flush()
-					if err != nil {
-						log.Println("[error] Writing tile expire list", err)
-					}
-				}
-
-				finishedImport()
+		} else {
+			lagAlerted = false
+		}
+	}
 
+	lastSeq := s.Sequence
+	importDiff := func(seqID int, fname string, seqTime time.Time) {
+		for {
+			for admin.Paused() {
 				select {
 				case <-sigc:
 					shutdown()
-				default:
+				case <-admin.Wake():
 				}
+			}
+			if err := leader.Check(); err != nil {
+				log.Fatal("[fatal] Lost leader lock:", err)
+			}
+			log.Printf("[info] Importing #%d including changes till %s (%s behind)", seqID, seqTime, time.Since(seqTime).Truncate(time.Second))
+			finishedImport := log.Step(fmt.Sprintf("Importing #%d", seqID))
+			status.Importing(seqID, seqTime)
+
+			err := update(baseOpts, fname, geometryLimiter, tileExpireor, osmCache, diffCache, false, feed)
+
+			osmCache.Coords.Flush()
+			diffCache.Flush()
 
+			if err == nil && tilelist != nil && time.Since(lastTlFlush) > time.Second*30 {
+				// call at most once every 30 seconds to reduce files during the
+				// catch-up phase after the initial import
+				lastTlFlush = time.Now()
+				err := tilelist.Flush()
 				if err != nil {
-					log.Printf("[error] Importing #%d: %s", seqID, err)
-					log.Println("[info] Retrying in", exp.Duration())
-					// TODO handle <-sigc during wait
-					exp.Wait()
-				} else {
-					exp.Reset()
-					break
+					log.Println("[error] Writing tile expire list", err)
 				}
 			}
-			if os.Getenv("IMPOSM3_SINGLE_DIFF") != "" {
+
+			finishedImport()
+
+			select {
+			case <-sigc:
 				shutdown()
-				return
+			default:
+			}
+
+			if err != nil {
+				log.Printf("[error] Importing #%d: %s", seqID, err)
+				status.Error(err)
+				failedDiffs++
+				if failedDiffs == baseOpts.Alerts.DiffErrors {
+					alerts.Send(alert.DiffErrors, fmt.Sprintf("Importing #%d failed %d times: %s", seqID, failedDiffs, err))
+				}
+				checkLag()
+				log.Println("[info] Retrying in", exp.Duration())
+				select {
+				case <-sigc:
+					shutdown()
+				case <-admin.Wake():
+				case <-time.After(exp.Duration()):
+				}
+				exp.Increase()
+			} else {
+				status.Success()
+				failedDiffs = 0
+				lastSeq = seqID
+				lastSeqTime = seqTime
+				checkLag()
+				exp.Reset()
+				break
+			}
+		}
+		if os.Getenv("IMPOSM3_SINGLE_DIFF") != "" {
+			shutdown()
+		}
+	}
+
+	for {
+		seqs := nextSeq
+		trigger := admin.Trigger()
+		if admin.Paused() {
+			// nil channel blocks, wait for wake up from admin API
+			seqs = nil
+			trigger = nil
+		}
+		select {
+		case <-sigc:
+			shutdown()
+		case <-lagTicker:
+			checkLag()
+		case <-admin.Wake():
+			// check paused state again
+		case <-trigger:
+			// the downloader might still wait for the next interval
+			seq, err := fetchSequence(baseOpts.DiffDir, replicationURL, lastSeq+1)
+			if err != nil {
+				log.Printf("[error] Downloading #%d: %s", lastSeq+1, err)
+				continue
+			}
+			if seq == nil {
+				log.Printf("[info] #%d is not available yet", lastSeq+1)
+				continue
+			}
+			importDiff(seq.Sequence, seq.Filename, seq.Time)
+		case seq := <-seqs:
+			if seq.Error != nil {
+				log.Printf("[error] Downloading #%d: %s", seq.Sequence, seq.Error)
+				continue
 			}
+			if seq.Sequence <= lastSeq {
+				// already imported after a trigger
+				continue
+			}
+			importDiff(seq.Sequence, seq.Filename, seq.Time)
 		}
 	}
 }
 
+// openProductionDB opens the database of the production schema for
+// connections that are kept open during run mode, independent of the
+// diff imports.
+func openProductionDB(baseOpts config.Base) (database.DB, error) {
+	tagmapping, err := mapping.FromFile(baseOpts.MappingFile)
+	if err != nil {
+		return nil, err
+	}
+	dbConf := database.Config{
+		ConnectionParams: baseOpts.Connection,
+		Srid:             baseOpts.Srid,
+		ImportSchema:     baseOpts.Schemas.Production,
+		ProductionSchema: baseOpts.Schemas.Production,
+		BackupSchema:     baseOpts.Schemas.Backup,
+		StateTable:       baseOpts.StateTable,
+		TablePrefix:      baseOpts.TablePrefix,
+		TableSuffix:      baseOpts.TableSuffix,
+	}
+	db, err := database.Open(dbConf, &tagmapping.Conf)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening database")
+	}
+	return db, nil
+}
+
 type expBackoff struct {
 	current time.Duration
 	min     time.Duration
@@ -171,8 +327,8 @@ func (eb *expBackoff) Duration() time.Duration {
 	return eb.current
 }
 
-func (eb *expBackoff) Wait() {
-	time.Sleep(eb.current)
+// Increase doubles the duration, up to max.
+func (eb *expBackoff) Increase() {
 	eb.current = eb.current * 2
 	if eb.current > eb.max {
 		eb.current = eb.max
@@ -0,0 +1,50 @@
+package update
+
+import (
+	"os"
+	"path/filepath"
+
+	diffstate "github.com/omniscale/go-osm/state"
+	"github.com/omniscale/imposm3/config"
+	"github.com/omniscale/imposm3/database"
+	"github.com/omniscale/imposm3/log"
+	"github.com/pkg/errors"
+)
+
+// restoreLastState writes the state from the -state-table to the
+// last.state.txt of the diff directory, if the file does not exist (e.g.
+// in a new container without a persistent diff directory).
+func restoreLastState(baseOpts config.Base) error {
+	if baseOpts.StateTable == "" {
+		return nil
+	}
+	lastStateFile := filepath.Join(baseOpts.DiffDir, LastStateFilename)
+	if _, err := os.Stat(lastStateFile); !os.IsNotExist(err) {
+		return err
+	}
+
+	db, err := openProductionDB(baseOpts)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	store, ok := db.(database.StateStore)
+	if !ok {
+		return errors.New("database does not support state_table")
+	}
+	s, err := store.ReadState()
+	if err != nil {
+		return errors.Wrap(err, "reading state")
+	}
+	if s == nil {
+		return nil
+	}
+	if err := os.MkdirAll(baseOpts.DiffDir, 0755); err != nil {
+		return err
+	}
+	if err := diffstate.WriteFile(lastStateFile, s); err != nil {
+		return errors.Wrapf(err, "writing %s", lastStateFile)
+	}
+	log.Printf("[info] Restored %s with sequence #%d from %s", lastStateFile, s.Sequence, baseOpts.StateTable)
+	return nil
+}
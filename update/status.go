@@ -0,0 +1,158 @@
+package update
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/omniscale/imposm3/log"
+)
+
+// statusInterval is the interval for rewriting the status file, even if
+// nothing changed. Watchdogs can check the updated timestamp to detect
+// whether the process is still alive.
+const statusInterval = 30 * time.Second
+
+// runStatus is written as JSON to the -status-file during run mode.
+type runStatus struct {
+	PID          int        `json:"pid"`
+	Hostname     string     `json:"hostname"`
+	Started      time.Time  `json:"started"`
+	Updated      time.Time  `json:"updated"`
+	State        string     `json:"state"`
+	StateSince   time.Time  `json:"state_since"`
+	Sequence     int        `json:"sequence"`
+	SequenceTime *time.Time `json:"sequence_time,omitempty"`
+	LastSuccess  *time.Time `json:"last_success,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	Paused       bool       `json:"paused"`
+}
+
+// statusFile tracks the runStatus and periodically writes it to filename,
+// if filename is not empty.
+type statusFile struct {
+	mu       sync.Mutex
+	filename string
+	status   runStatus
+	stop     chan struct{}
+}
+
+func newStatusFile(filename string) *statusFile {
+	hostname, _ := os.Hostname()
+	now := time.Now()
+	return &statusFile{
+		filename: filename,
+		status: runStatus{
+			PID:        os.Getpid(),
+			Hostname:   hostname,
+			Started:    now,
+			State:      "waiting",
+			StateSince: now,
+		},
+		stop: make(chan struct{}),
+	}
+}
+
+// Start writes the status and rewrites it every statusInterval until Stop
+// is called.
+func (s *statusFile) Start() {
+	if s.filename == "" {
+		return
+	}
+	s.update(func(*runStatus) {})
+	go func() {
+		ticker := time.NewTicker(statusInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				s.update(func(*runStatus) {})
+			}
+		}
+	}()
+}
+
+func (s *statusFile) Stop() {
+	s.update(func(st *runStatus) { st.State = "stopped" })
+	close(s.stop)
+}
+
+func (s *statusFile) Importing(seq int, seqTime time.Time) {
+	s.update(func(st *runStatus) {
+		st.State = "importing"
+		st.Sequence = seq
+		st.SequenceTime = &seqTime
+	})
+}
+
+func (s *statusFile) Success() {
+	s.update(func(st *runStatus) {
+		st.State = "waiting"
+		now := time.Now()
+		st.LastSuccess = &now
+		st.LastError = ""
+	})
+}
+
+func (s *statusFile) Error(err error) {
+	s.update(func(st *runStatus) {
+		st.State = "retrying"
+		st.LastError = err.Error()
+	})
+}
+
+func (s *statusFile) SetPaused(paused bool) {
+	s.update(func(st *runStatus) { st.Paused = paused })
+}
+
+// Status returns a copy of the current status.
+func (s *statusFile) Status() runStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+func (s *statusFile) update(f func(*runStatus)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prevState := s.status.State
+	f(&s.status)
+	s.status.Updated = time.Now()
+	if s.status.State != prevState {
+		s.status.StateSince = s.status.Updated
+	}
+	if s.filename == "" {
+		return
+	}
+	if err := s.write(); err != nil {
+		log.Println("[warn] Writing status file", err)
+	}
+}
+
+// write replaces the status file atomically, so that readers never see
+// partial content.
+func (s *statusFile) write() error {
+	b, err := json.MarshalIndent(s.status, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.filename), ".status-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.filename)
+}
@@ -0,0 +1,145 @@
+package writer
+
+import (
+	"math"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/database"
+	geomp "github.com/omniscale/imposm3/geom"
+	"github.com/omniscale/imposm3/geom/geos"
+	"github.com/omniscale/imposm3/log"
+	"github.com/omniscale/imposm3/mapping"
+	"github.com/omniscale/imposm3/mapping/config"
+	"github.com/pkg/errors"
+)
+
+// maxSimplifySteps is the number of times the simplify tolerance is
+// increased before a geometry is skipped.
+const maxSimplifySteps = 12
+
+// limited returns an inserter that applies the geometry_limit of the
+// tables to all linestrings, polygons and relation members before they are
+// inserted.
+func (writer *OsmElemWriter) limited(g *geos.Geos) database.Inserter {
+	return &limitInserter{Inserter: writer.inserter, writer: writer, g: g}
+}
+
+type limitInserter struct {
+	database.Inserter
+	writer *OsmElemWriter
+	g      *geos.Geos
+}
+
+func (li *limitInserter) InsertLineString(elem osm.Element, geom geomp.Geometry, matches []mapping.Match) error {
+	return li.insert(elem, geom, matches, li.Inserter.InsertLineString)
+}
+
+func (li *limitInserter) InsertPolygon(elem osm.Element, geom geomp.Geometry, matches []mapping.Match) error {
+	return li.insert(elem, geom, matches, li.Inserter.InsertPolygon)
+}
+
+func (li *limitInserter) InsertRelationMember(rel osm.Relation, m osm.Member, mi int, geom geomp.Geometry, matches []mapping.Match) error {
+	return li.insert(rel.Element, geom, matches, func(_ osm.Element, geom geomp.Geometry, matches []mapping.Match) error {
+		return li.Inserter.InsertRelationMember(rel, m, mi, geom, matches)
+	})
+}
+
+type insertFunc func(osm.Element, geomp.Geometry, []mapping.Match) error
+
+// insert groups matches by their geometry limit and inserts each group
+// with a geometry that is within that limit. Oversized geometries that
+// can not or should not be simplified are reported as errors.
+func (li *limitInserter) insert(elem osm.Element, geom geomp.Geometry, matches []mapping.Match, insert insertFunc) error {
+	exceeded := false
+	for _, m := range matches {
+		if l := m.GeometryLimit(); l != nil && !withinLimit(li.g, geom, l) {
+			exceeded = true
+			break
+		}
+	}
+	if !exceeded {
+		return insert(elem, geom, matches)
+	}
+
+	var limits []*config.GeometryLimit
+	groups := make(map[*config.GeometryLimit][]mapping.Match)
+	for _, m := range matches {
+		l := m.GeometryLimit()
+		if l != nil && withinLimit(li.g, geom, l) {
+			l = nil
+		}
+		if _, ok := groups[l]; !ok {
+			limits = append(limits, l)
+		}
+		groups[l] = append(groups[l], m)
+	}
+
+	for _, l := range limits {
+		group := groups[l]
+		if l == nil {
+			if err := insert(elem, geom, group); err != nil {
+				return err
+			}
+			continue
+		}
+		if l.Skip {
+			li.skip(elem, group, errors.Errorf("geometry with %d vertices and %d bytes exceeds geometry_limit",
+				li.g.NumCoordinates(geom.Geom), len(geom.Wkb)/2))
+			continue
+		}
+		simplified, err := simplifyToLimit(li.g, geom, l)
+		if err != nil {
+			li.skip(elem, group, err)
+			continue
+		}
+		err = insert(elem, simplified, group)
+		li.g.Destroy(simplified.Geom)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (li *limitInserter) skip(elem osm.Element, matches []mapping.Match, err error) {
+	log.Printf("[warn] Skipping %d for %s: %s", elem.ID, matches[0].Table.Name, err)
+	li.writer.reportError(elem, matches, err)
+}
+
+// withinLimit returns whether geom has no more than MaxVertices vertices
+// and no more than MaxBytes bytes as EWKB. Rows without geometry (relation
+// tables) are always within the limit.
+func withinLimit(g *geos.Geos, geom geomp.Geometry, l *config.GeometryLimit) bool {
+	if l.MaxBytes > 0 && len(geom.Wkb)/2 > l.MaxBytes {
+		return false
+	}
+	if l.MaxVertices > 0 && geom.Geom != nil && int(g.NumCoordinates(geom.Geom)) > l.MaxVertices {
+		return false
+	}
+	return true
+}
+
+// simplifyToLimit simplifies geom with an increasing tolerance, till it is
+// within the limit. The tolerance starts at a fraction of the size of the
+// geometry and is increased four-fold with each step.
+func simplifyToLimit(g *geos.Geos, geom geomp.Geometry, l *config.GeometryLimit) (geomp.Geometry, error) {
+	b := geom.Geom.Bounds()
+	if b == geos.NilBounds {
+		return geomp.Geometry{}, errors.New("empty geometry exceeds geometry_limit")
+	}
+	tolerance := math.Max(b.MaxX-b.MinX, b.MaxY-b.MinY) / 100000
+	for i := 0; i < maxSimplifySteps; i++ {
+		simplified := g.SimplifyPreserveTopology(geom.Geom, tolerance)
+		if simplified == nil {
+			return geomp.Geometry{}, errors.New("simplifying geometry to geometry_limit failed")
+		}
+		result := geomp.Geometry{Geom: simplified, Wkb: g.AsEwkbHex(simplified)}
+		if result.Wkb != nil && withinLimit(g, result, l) {
+			return result, nil
+		}
+		g.Destroy(simplified)
+		tolerance *= 4
+	}
+	return geomp.Geometry{}, errors.Errorf("geometry with %d vertices and %d bytes could not be simplified to geometry_limit",
+		g.NumCoordinates(geom.Geom), len(geom.Wkb)/2)
+}
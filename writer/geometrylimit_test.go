@@ -0,0 +1,244 @@
+package writer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	osm "github.com/omniscale/go-osm"
+	geomp "github.com/omniscale/imposm3/geom"
+	"github.com/omniscale/imposm3/geom/geos"
+	"github.com/omniscale/imposm3/mapping"
+	"github.com/omniscale/imposm3/mapping/config"
+)
+
+const limitMapping = `
+tables:
+  roads:
+    type: linestring
+    columns:
+    - {name: osm_id, type: id}
+    mapping:
+      highway: [__any__]
+  roads_large:
+    type: linestring
+    geometry_limit: {max_vertices: 10000}
+    columns:
+    - {name: osm_id, type: id}
+    mapping:
+      highway: [__any__]
+  roads_simplified:
+    type: linestring
+    geometry_limit: {max_vertices: 100}
+    columns:
+    - {name: osm_id, type: id}
+    mapping:
+      highway: [__any__]
+  roads_skipped:
+    type: linestring
+    geometry_limit: {max_vertices: 100, skip: true}
+    columns:
+    - {name: osm_id, type: id}
+    mapping:
+      highway: [__any__]
+  routes:
+    type: relation
+    geometry_limit: {max_vertices: 10}
+    columns:
+    - {name: osm_id, type: id}
+    mapping:
+      type: [route]
+  route_members:
+    type: relation_member
+    geometry_limit: {max_vertices: 100}
+    columns:
+    - {name: osm_id, type: id}
+    mapping:
+      type: [route]
+`
+
+// zigzag returns a linestring with n vertices
+func zigzag(t *testing.T, g *geos.Geos, n int) geomp.Geometry {
+	coords := make([]string, n)
+	for i := range coords {
+		coords[i] = fmt.Sprintf("%d %d", i, i%2*10)
+	}
+	geom := g.FromWkt("LINESTRING(" + strings.Join(coords, ",") + ")")
+	if geom == nil {
+		t.Fatal("invalid linestring")
+	}
+	return geomp.Geometry{Geom: geom, Wkb: g.AsEwkbHex(geom)}
+}
+
+type insert struct {
+	tables   []string
+	vertices int
+	member   int
+}
+
+type recordingInserter struct {
+	g        *geos.Geos
+	inserts  []insert
+	reported []string
+}
+
+func (ri *recordingInserter) record(geom geomp.Geometry, matches []mapping.Match, member int) error {
+	ins := insert{member: member}
+	if geom.Geom != nil {
+		ins.vertices = int(ri.g.NumCoordinates(geom.Geom))
+	}
+	for _, m := range matches {
+		ins.tables = append(ins.tables, m.Table.Name)
+	}
+	sort.Strings(ins.tables)
+	ri.inserts = append(ri.inserts, ins)
+	return nil
+}
+
+func (ri *recordingInserter) InsertPoint(elem osm.Element, geom geomp.Geometry, matches []mapping.Match) error {
+	return ri.record(geom, matches, -1)
+}
+
+func (ri *recordingInserter) InsertLineString(elem osm.Element, geom geomp.Geometry, matches []mapping.Match) error {
+	return ri.record(geom, matches, -1)
+}
+
+func (ri *recordingInserter) InsertPolygon(elem osm.Element, geom geomp.Geometry, matches []mapping.Match) error {
+	return ri.record(geom, matches, -1)
+}
+
+func (ri *recordingInserter) InsertRelationMember(rel osm.Relation, m osm.Member, mi int, geom geomp.Geometry, matches []mapping.Match) error {
+	return ri.record(geom, matches, mi)
+}
+
+func (ri *recordingInserter) ReportError(elem osm.Element, matches []mapping.Match, err error) {
+	for _, m := range matches {
+		ri.reported = append(ri.reported, m.Table.Name)
+	}
+}
+
+func newLimitInserter(t *testing.T, g *geos.Geos) (*mapping.Mapping, *recordingInserter, *limitInserter) {
+	m, err := mapping.New([]byte(limitMapping))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ri := &recordingInserter{g: g}
+	writer := &OsmElemWriter{inserter: ri}
+	return m, ri, writer.limited(g).(*limitInserter)
+}
+
+func TestLimitInserterGroups(t *testing.T) {
+	g := geos.NewGeos()
+	defer g.Finish()
+	g.SetHandleSrid(3857)
+	m, ri, li := newLimitInserter(t, g)
+
+	way := osm.Way{Element: osm.Element{ID: 1, Tags: osm.Tags{"highway": "primary"}}}
+	matches := m.LineStringMatcher.MatchWay(&way)
+	if len(matches) != 4 {
+		t.Fatalf("unexpected matches %v", matches)
+	}
+	if err := li.InsertLineString(way.Element, zigzag(t, g, 1000), matches); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ri.inserts) != 2 {
+		t.Fatalf("unexpected inserts %v", ri.inserts)
+	}
+	sort.Slice(ri.inserts, func(i, j int) bool { return ri.inserts[i].tables[0] < ri.inserts[j].tables[0] })
+	// tables within the limit are inserted together with the original geometry
+	if ins := ri.inserts[0]; strings.Join(ins.tables, ",") != "roads,roads_large" || ins.vertices != 1000 {
+		t.Errorf("unexpected insert %v", ins)
+	}
+	if ins := ri.inserts[1]; strings.Join(ins.tables, ",") != "roads_simplified" || ins.vertices > 100 || ins.vertices < 2 {
+		t.Errorf("unexpected insert %v", ins)
+	}
+	if strings.Join(ri.reported, ",") != "roads_skipped" {
+		t.Errorf("unexpected reported tables %v", ri.reported)
+	}
+}
+
+func TestLimitInserterSmallGeometry(t *testing.T) {
+	g := geos.NewGeos()
+	defer g.Finish()
+	g.SetHandleSrid(3857)
+	m, ri, li := newLimitInserter(t, g)
+
+	way := osm.Way{Element: osm.Element{ID: 1, Tags: osm.Tags{"highway": "primary"}}}
+	if err := li.InsertLineString(way.Element, zigzag(t, g, 10), m.LineStringMatcher.MatchWay(&way)); err != nil {
+		t.Fatal(err)
+	}
+	if len(ri.inserts) != 1 || len(ri.inserts[0].tables) != 4 || ri.inserts[0].vertices != 10 {
+		t.Errorf("unexpected inserts %v", ri.inserts)
+	}
+	if len(ri.reported) != 0 {
+		t.Errorf("unexpected reported tables %v", ri.reported)
+	}
+}
+
+func TestLimitInserterRelations(t *testing.T) {
+	g := geos.NewGeos()
+	defer g.Finish()
+	m, ri, li := newLimitInserter(t, g)
+
+	rel := osm.Relation{Element: osm.Element{ID: 1, Tags: osm.Tags{"type": "route"}}}
+	// relation rows have no geometry and are always within the limit
+	if err := li.InsertPolygon(rel.Element, geomp.Geometry{}, m.RelationMatcher.MatchRelation(&rel)); err != nil {
+		t.Fatal(err)
+	}
+	if len(ri.inserts) != 1 || strings.Join(ri.inserts[0].tables, ",") != "routes" {
+		t.Errorf("unexpected inserts %v", ri.inserts)
+	}
+}
+
+func TestLimitInserterRelationMembers(t *testing.T) {
+	g := geos.NewGeos()
+	defer g.Finish()
+	g.SetHandleSrid(3857)
+	m, ri, li := newLimitInserter(t, g)
+
+	rel := osm.Relation{Element: osm.Element{ID: 1, Tags: osm.Tags{"type": "route"}}}
+	matches := m.RelationMemberMatcher.MatchRelation(&rel)
+	if err := li.InsertRelationMember(rel, osm.Member{ID: 2, Type: osm.WayMember}, 3, zigzag(t, g, 1000), matches); err != nil {
+		t.Fatal(err)
+	}
+	if len(ri.inserts) != 1 {
+		t.Fatalf("unexpected inserts %v", ri.inserts)
+	}
+	if ins := ri.inserts[0]; strings.Join(ins.tables, ",") != "route_members" || ins.member != 3 || ins.vertices > 100 {
+		t.Errorf("unexpected insert %v", ins)
+	}
+}
+
+func TestSimplifyToLimit(t *testing.T) {
+	g := geos.NewGeos()
+	defer g.Finish()
+	g.SetHandleSrid(3857)
+	geom := zigzag(t, g, 1000)
+
+	for _, l := range []*config.GeometryLimit{
+		{MaxVertices: 100},
+		{MaxBytes: 500},
+		{MaxVertices: 500, MaxBytes: 2000},
+	} {
+		simplified, err := simplifyToLimit(g, geom, l)
+		if err != nil {
+			t.Errorf("%v: %s", l, err)
+			continue
+		}
+		if !withinLimit(g, simplified, l) {
+			t.Errorf("%v: simplified geometry with %d vertices and %d bytes not within limit",
+				l, g.NumCoordinates(simplified.Geom), len(simplified.Wkb)/2)
+		}
+		if n := g.NumCoordinates(simplified.Geom); n < 2 {
+			t.Errorf("%v: invalid simplified geometry with %d vertices", l, n)
+		}
+		g.Destroy(simplified.Geom)
+	}
+
+	// linestrings need at least two vertices
+	if _, err := simplifyToLimit(g, geom, &config.GeometryLimit{MaxVertices: 1}); err == nil {
+		t.Error("expected error for unreachable limit")
+	}
+}
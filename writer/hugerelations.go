@@ -0,0 +1,177 @@
+package writer
+
+import (
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/cache"
+	"github.com/omniscale/imposm3/expire"
+	geomp "github.com/omniscale/imposm3/geom"
+	geosp "github.com/omniscale/imposm3/geom/geos"
+	"github.com/omniscale/imposm3/log"
+)
+
+// handleHugeRelation writes a relation with more than maxRelationMembers
+// members. Other than for regular relations, the member ways are loaded
+// one at a time in two passes: the first pass builds the rings of the
+// multipolygon (with geom.RingBuilder, depending on the hugeRelationMode),
+// the second pass inserts the relation_member rows and updates the diff
+// cache and the expired tiles. Member relations are not resolved.
+func (rw *RelationWriter) handleHugeRelation(r *osm.Relation, geos *geosp.Geos) {
+	relMatches := rw.relationMatcher.MatchRelation(r)
+	relMemberMatches := rw.relationMemberMatcher.MatchRelation(r)
+	polygonMatches := rw.polygonMatcher.MatchRelation(r)
+
+	var rb *geomp.RingBuilder
+	if polygonMatches != nil {
+		switch rw.hugeRelationMode {
+		case HugeRelationBuild, HugeRelationSimplify:
+			rb = geomp.NewRingBuilder(geos, rw.maxGap)
+			if rw.hugeRelationMode == HugeRelationSimplify {
+				rb.SetSimplifyTolerance(rw.hugeRelationTolerance)
+			}
+		default:
+			log.Printf("[warn]: skipping multipolygon of relation %d with %d members (max %d)", r.ID, len(r.Members), rw.maxRelationMembers)
+			polygonMatches = nil
+		}
+	}
+	if relMatches == nil && relMemberMatches == nil && polygonMatches == nil {
+		return
+	}
+
+	// first pass: build the rings and check that all members are cached
+	var buildErr error
+	err := rw.eachHugeMember(r, relMemberMatches != nil, func(mi int, m osm.Member) {
+		if rb != nil && m.Way != nil && buildErr == nil {
+			buildErr = rb.AddWay(m.Way)
+		}
+	})
+	if err != nil {
+		if rb != nil {
+			rb.Destroy()
+		}
+		if err != cache.NotFound {
+			log.Println("[warn]: ", err)
+		}
+		return
+	}
+
+	inserted := false
+	if rb != nil {
+		var prepedRel geomp.PreparedRelation
+		err := buildErr
+		if err == nil {
+			// Build sets the roles of the members, keep the original roles
+			// for the relation_member rows
+			polygonRel := *r
+			polygonRel.Members = append([]osm.Member(nil), r.Members...)
+			prepedRel, err = rb.Prepare(&polygonRel, rw.srid)
+		} else {
+			rb.Destroy()
+		}
+		if err != nil {
+			if errl, ok := err.(ErrorLevel); !ok || errl.Level() > 0 {
+				log.Println("[warn]: ", err)
+			}
+			rw.reportError(r.Element, polygonMatches, err)
+		} else if insertMultiPolygon(rw, r, polygonMatches, prepedRel, geos) {
+			inserted = true
+		}
+	}
+	if handleRelation(rw, r, geos) {
+		inserted = true
+	}
+	if relMemberMatches != nil {
+		inserted = true
+	}
+	if !inserted || (relMemberMatches == nil && rw.diffCache == nil && rw.expireor == nil) {
+		return
+	}
+
+	// second pass: insert relation_member rows, update diff cache and
+	// expire tiles
+	inserter := rw.limited(geos)
+	rel := osm.Relation(*r)
+	rel.ID = rw.relID(r.ID)
+	err = rw.eachHugeMember(r, relMemberMatches != nil, func(mi int, m osm.Member) {
+		if relMemberMatches != nil {
+			var g *geosp.Geom
+			var err error
+			if m.Node != nil {
+				g, err = geomp.Point(geos, *m.Node)
+			} else if m.Way != nil {
+				g, err = geomp.LineString(geos, m.Way.Nodes)
+			}
+			var gelem geomp.Geometry
+			if err == nil {
+				gelem, err = memberGeometry(geos, g)
+			}
+			if err != nil {
+				log.Println("[warn]: ", err)
+				rw.reportError(r.Element, relMemberMatches, err)
+			} else {
+				inserter.InsertRelationMember(rel, m, mi, gelem, relMemberMatches)
+			}
+		}
+		if m.Way != nil {
+			if rw.diffCache != nil {
+				rw.diffCache.Coords.AddFromWay(m.Way)
+			}
+			if rw.expireor != nil {
+				expire.ExpireProjectedNodes(rw.expireor, m.Way.Nodes, rw.srid, true)
+			}
+		}
+	})
+	if err != nil && err != cache.NotFound {
+		log.Println("[warn]: ", err)
+	}
+	if rw.diffCache != nil {
+		rw.diffCache.Ways.AddFromMembers(r.ID, r.Members)
+		rw.diffCache.CoordsRel.AddFromMembers(r.ID, r.Members)
+	}
+}
+
+// eachHugeMember calls fn for each member of r. Ways are loaded with their
+// nodes in the target SRID. Nodes and relations are only loaded and passed
+// to fn if allMembers is true. Members are loaded into a copy of
+// r.Members[i], so that only one way is in memory at a time.
+func (rw *RelationWriter) eachHugeMember(r *osm.Relation, allMembers bool, fn func(int, osm.Member)) error {
+	for i, m := range r.Members {
+		switch m.Type {
+		case osm.WayMember:
+			way, err := rw.osmCache.Ways.GetWay(m.ID)
+			if err != nil {
+				return err
+			}
+			if err := rw.osmCache.Coords.FillWay(way); err != nil {
+				return err
+			}
+			rw.NodesToSrid(way.Nodes)
+			m.Way = way
+			m.Element = &way.Element
+		case osm.NodeMember:
+			if !allMembers {
+				continue
+			}
+			nd, err := rw.osmCache.Nodes.GetNode(m.ID)
+			if err == cache.NotFound {
+				nd, err = rw.osmCache.Coords.GetCoord(m.ID)
+			}
+			if err != nil {
+				return err
+			}
+			rw.NodeToSrid(nd)
+			m.Node = nd
+			m.Element = &nd.Element
+		case osm.RelationMember:
+			if !allMembers {
+				continue
+			}
+			mrel, err := rw.osmCache.Relations.GetRelation(m.ID)
+			if err != nil {
+				return err
+			}
+			m.Element = &mrel.Element
+		}
+		fn(i, m)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package writer
+
+import (
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/cache"
+	geomp "github.com/omniscale/imposm3/geom"
+	geosp "github.com/omniscale/imposm3/geom/geos"
+	"github.com/omniscale/imposm3/log"
+)
+
+// memberRelationWays returns all member ways of the relation id. Ways of
+// member relations are resolved recursively up to depth levels. Missing
+// ways and relations are skipped, as nested relations often extend beyond
+// the imported area.
+func (rw *RelationWriter) memberRelationWays(id int64, depth int, visited map[int64]struct{}) []osm.Member {
+	if _, ok := visited[id]; ok {
+		// relations can contain each other
+		return nil
+	}
+	visited[id] = struct{}{}
+
+	rel, err := rw.osmCache.Relations.GetRelation(id)
+	if err != nil {
+		if err != cache.NotFound {
+			log.Println("[warn]: ", err)
+		}
+		return nil
+	}
+
+	var ways []osm.Member
+	for _, m := range rel.Members {
+		switch m.Type {
+		case osm.WayMember:
+			way, err := rw.osmCache.Ways.GetWay(m.ID)
+			if err != nil {
+				if err != cache.NotFound {
+					log.Println("[warn]: ", err)
+				}
+				continue
+			}
+			if err := rw.osmCache.Coords.FillWay(way); err != nil {
+				if err != cache.NotFound {
+					log.Println("[warn]: ", err)
+				}
+				continue
+			}
+			rw.NodesToSrid(way.Nodes)
+			m.Way = way
+			m.Element = &way.Element
+			ways = append(ways, m)
+		case osm.RelationMember:
+			if depth > 1 {
+				ways = append(ways, rw.memberRelationWays(m.ID, depth-1, visited)...)
+			}
+		}
+	}
+	return ways
+}
+
+// waysGeom returns a MultiLineString of all ways, or nil if no way has a
+// valid geometry.
+func waysGeom(g *geosp.Geos, ways []osm.Member) *geosp.Geom {
+	var lines []*geosp.Geom
+	for _, m := range ways {
+		line, err := geomp.LineString(g, m.Way.Nodes)
+		if err != nil {
+			continue
+		}
+		// the MultiLineString takes ownership of its lines, clone line
+		// as it is also destroyed by its finalizer
+		lines = append(lines, g.Clone(line))
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	multiLine := g.MultiLineString(lines)
+	if multiLine != nil {
+		g.DestroyLater(multiLine)
+	}
+	return multiLine
+}
@@ -57,12 +57,14 @@ func (nw *NodeWriter) loop() {
 				if errl, ok := err.(ErrorLevel); !ok || errl.Level() > 0 {
 					log.Println("[warn]: ", err)
 				}
+				nw.reportError(n.Element, matches, err)
 				continue
 			}
 
 			geom, err := geomp.AsGeomElement(geos, point)
 			if err != nil {
 				log.Println("[warn]: ", err)
+				nw.reportError(n.Element, matches, err)
 				continue
 			}
 
@@ -76,6 +78,7 @@ func (nw *NodeWriter) loop() {
 				if len(parts) >= 1 {
 					if err := nw.inserter.InsertPoint(n.Element, geom, matches); err != nil {
 						log.Println("[warn]: ", err)
+						nw.reportError(n.Element, matches, err)
 						continue
 					}
 					inserted = true
@@ -83,6 +86,7 @@ func (nw *NodeWriter) loop() {
 			} else {
 				if err := nw.inserter.InsertPoint(n.Element, geom, matches); err != nil {
 					log.Println("[warn]: ", err)
+					nw.reportError(n.Element, matches, err)
 					continue
 				}
 				inserted = true
@@ -77,6 +77,10 @@ func (rw *RelationWriter) loop() {
 NextRel:
 	for r := range rw.rel {
 		rw.progress.AddRelations(1)
+		if rw.maxRelationMembers > 0 && len(r.Members) > rw.maxRelationMembers {
+			rw.handleHugeRelation(r, geos)
+			continue
+		}
 		err := rw.osmCache.Ways.FillMembers(r.Members)
 		if err != nil {
 			if err != cache.NotFound {
@@ -105,8 +109,10 @@ NextRel:
 
 		inserted := false
 
-		if handleRelationMembers(rw, r, geos) {
+		insertedMembers, nestedWays := handleRelationMembers(rw, r, geos)
+		if insertedMembers {
 			inserted = true
+			allMembers = append(allMembers[:len(allMembers):len(allMembers)], nestedWays...)
 		}
 		if handleRelation(rw, r, geos) {
 			inserted = true
@@ -147,9 +153,15 @@ func handleMultiPolygon(rw *RelationWriter, r *osm.Relation, geos *geosp.Geos) b
 		if errl, ok := err.(ErrorLevel); !ok || errl.Level() > 0 {
 			log.Println("[warn]: ", err)
 		}
+		rw.reportError(r.Element, matches, err)
 		return false
 	}
+	return insertMultiPolygon(rw, r, matches, prepedRel, geos)
+}
 
+// insertMultiPolygon builds the multipolygon of prepedRel and inserts it
+// for all matches.
+func insertMultiPolygon(rw *RelationWriter, r *osm.Relation, matches []mapping.Match, prepedRel geomp.PreparedRelation, geos *geosp.Geos) bool {
 	// build the multipolygon
 	geom, err := prepedRel.Build()
 	if geom.Geom != nil {
@@ -159,6 +171,7 @@ func handleMultiPolygon(rw *RelationWriter, r *osm.Relation, geos *geosp.Geos) b
 		if errl, ok := err.(ErrorLevel); !ok || errl.Level() > 0 {
 			log.Println("[warn]: ", err)
 		}
+		rw.reportError(r.Element, matches, err)
 		return false
 	}
 
@@ -179,22 +192,24 @@ func handleMultiPolygon(rw *RelationWriter, r *osm.Relation, geos *geosp.Geos) b
 			rel := osm.Relation(*r)
 			rel.ID = rw.relID(r.ID)
 			geom = geomp.Geometry{Geom: g, Wkb: geos.AsEwkbHex(g)}
-			err := rw.inserter.InsertPolygon(rel.Element, geom, matches)
+			err := rw.limited(geos).InsertPolygon(rel.Element, geom, matches)
 			if err != nil {
 				if errl, ok := err.(ErrorLevel); !ok || errl.Level() > 0 {
 					log.Println("[warn]: ", err)
 				}
+				rw.reportError(rel.Element, matches, err)
 				continue
 			}
 		}
 	} else {
 		rel := osm.Relation(*r)
 		rel.ID = rw.relID(r.ID)
-		err := rw.inserter.InsertPolygon(rel.Element, geom, matches)
+		err := rw.limited(geos).InsertPolygon(rel.Element, geom, matches)
 		if err != nil {
 			if errl, ok := err.(ErrorLevel); !ok || errl.Level() > 0 {
 				log.Println("[warn]: ", err)
 			}
+			rw.reportError(rel.Element, matches, err)
 			return false
 		}
 	}
@@ -209,15 +224,20 @@ func handleRelation(rw *RelationWriter, r *osm.Relation, geos *geosp.Geos) bool
 	}
 	rel := osm.Relation(*r)
 	rel.ID = rw.relID(r.ID)
-	rw.inserter.InsertPolygon(rel.Element, geomp.Geometry{}, relMatches)
+	rw.limited(geos).InsertPolygon(rel.Element, geomp.Geometry{}, relMatches)
 	return true
 }
 
-func handleRelationMembers(rw *RelationWriter, r *osm.Relation, geos *geosp.Geos) bool {
+// handleRelationMembers inserts all members of r into relation_member
+// tables. It returns the ways of all resolved member relations, as they
+// are not part of r.Members.
+func handleRelationMembers(rw *RelationWriter, r *osm.Relation, geos *geosp.Geos) (bool, []osm.Member) {
 	relMemberMatches := rw.relationMemberMatcher.MatchRelation(r)
 	if relMemberMatches == nil {
-		return false
+		return false, nil
 	}
+	var nestedWays []osm.Member
+	nestedGeoms := make(map[int]*geosp.Geom)
 	for i, m := range r.Members {
 		if m.Type == osm.RelationMember {
 			mrel, err := rw.osmCache.Relations.GetRelation(m.ID)
@@ -225,9 +245,16 @@ func handleRelationMembers(rw *RelationWriter, r *osm.Relation, geos *geosp.Geos
 				if err != cache.NotFound {
 					log.Println("[warn]: ", err)
 				}
-				return false
+				return false, nil
 			}
 			r.Members[i].Element = &mrel.Element
+			if rw.relationMemberDepth > 0 {
+				ways := rw.memberRelationWays(m.ID, rw.relationMemberDepth, map[int64]struct{}{r.ID: {}})
+				if g := waysGeom(geos, ways); g != nil {
+					nestedGeoms[i] = g
+				}
+				nestedWays = append(nestedWays, ways...)
+			}
 		} else if m.Type == osm.NodeMember {
 			nd, err := rw.osmCache.Nodes.GetNode(m.ID)
 			if err != nil {
@@ -237,11 +264,11 @@ func handleRelationMembers(rw *RelationWriter, r *osm.Relation, geos *geosp.Geos
 						if err != cache.NotFound {
 							log.Println("[warn]: ", err)
 						}
-						return false
+						return false, nil
 					}
 				} else {
 					log.Println("[warn]: ", err)
-					return false
+					return false, nil
 				}
 			}
 			rw.NodeToSrid(nd)
@@ -250,6 +277,7 @@ func handleRelationMembers(rw *RelationWriter, r *osm.Relation, geos *geosp.Geos
 		}
 	}
 
+	inserter := rw.limited(geos)
 	for mi, m := range r.Members {
 		var g *geosp.Geom
 		var err error
@@ -257,27 +285,35 @@ func handleRelationMembers(rw *RelationWriter, r *osm.Relation, geos *geosp.Geos
 			g, err = geomp.Point(geos, *m.Node)
 		} else if m.Way != nil {
 			g, err = geomp.LineString(geos, m.Way.Nodes)
+		} else if nested, ok := nestedGeoms[mi]; ok {
+			g = nested
 		}
 
 		if err != nil {
 			log.Println("[warn]: ", err)
-			return false
+			rw.reportError(r.Element, relMemberMatches, err)
+			return false, nil
 		}
 
-		var gelem geomp.Geometry
-		if g == nil {
-			g = geos.FromWkt("POLYGON EMPTY")
-			gelem = geomp.Geometry{Geom: g, Wkb: geos.AsEwkbHex(g)}
-		} else {
-			gelem, err = geomp.AsGeomElement(geos, g)
-			if err != nil {
-				log.Println("[warn]: ", err)
-				return false
-			}
+		gelem, err := memberGeometry(geos, g)
+		if err != nil {
+			log.Println("[warn]: ", err)
+			rw.reportError(r.Element, relMemberMatches, err)
+			return false, nil
 		}
 		rel := osm.Relation(*r)
 		rel.ID = rw.relID(r.ID)
-		rw.inserter.InsertRelationMember(rel, m, mi, gelem, relMemberMatches)
+		inserter.InsertRelationMember(rel, m, mi, gelem, relMemberMatches)
 	}
-	return true
+	return true, nestedWays
+}
+
+// memberGeometry returns the geometry of a relation_member row, an empty
+// polygon if g is nil.
+func memberGeometry(geos *geosp.Geos, g *geosp.Geom) (geomp.Geometry, error) {
+	if g == nil {
+		g = geos.FromWkt("POLYGON EMPTY")
+		return geomp.Geometry{Geom: g, Wkb: geos.AsEwkbHex(g)}, nil
+	}
+	return geomp.AsGeomElement(geos, g)
 }
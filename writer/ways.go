@@ -101,6 +101,7 @@ func (ww *WayWriter) loop() {
 				if errl, ok := err.(ErrorLevel); !ok || errl.Level() > 0 {
 					log.Println("[warn]: ", err)
 				}
+				ww.reportError(w.Element, matches, err)
 				continue
 			}
 		}
@@ -114,6 +115,7 @@ func (ww *WayWriter) loop() {
 					if errl, ok := err.(ErrorLevel); !ok || errl.Level() > 0 {
 						log.Println("[warn]: ", err)
 					}
+					ww.reportError(w.Element, matches, err)
 					continue
 				}
 			}
@@ -173,22 +175,22 @@ func (ww *WayWriter) buildAndInsert(
 		for _, p := range parts {
 			geom = geomp.Geometry{Geom: p, Wkb: g.AsEwkbHex(p)}
 			if isPolygon {
-				if err := ww.inserter.InsertPolygon(way.Element, geom, matches); err != nil {
+				if err := ww.limited(g).InsertPolygon(way.Element, geom, matches); err != nil {
 					return err, false
 				}
 			} else {
-				if err := ww.inserter.InsertLineString(way.Element, geom, matches); err != nil {
+				if err := ww.limited(g).InsertLineString(way.Element, geom, matches); err != nil {
 					return err, false
 				}
 			}
 		}
 	} else {
 		if isPolygon {
-			if err := ww.inserter.InsertPolygon(way.Element, geom, matches); err != nil {
+			if err := ww.limited(g).InsertPolygon(way.Element, geom, matches); err != nil {
 				return err, false
 			}
 		} else {
-			if err := ww.inserter.InsertLineString(way.Element, geom, matches); err != nil {
+			if err := ww.limited(g).InsertLineString(way.Element, geom, matches); err != nil {
 				return err, false
 			}
 		}
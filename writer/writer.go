@@ -9,6 +9,7 @@ import (
 	"github.com/omniscale/imposm3/database"
 	"github.com/omniscale/imposm3/expire"
 	"github.com/omniscale/imposm3/geom/limit"
+	"github.com/omniscale/imposm3/mapping"
 	"github.com/omniscale/imposm3/proj"
 	"github.com/omniscale/imposm3/stats"
 )
@@ -32,20 +33,90 @@ type OsmElemWriter struct {
 	srid       int
 	expireor   expire.Expireor
 	concurrent bool
+	// concurrency is the number of goroutines in concurrent mode. Uses the
+	// number of CPUs if 0.
+	concurrency int
+	// maxRelationMembers is the maximum number of members of relations
+	// that are built. Unlimited if 0.
+	maxRelationMembers int
+	// relationMemberDepth is the number of levels of member relations
+	// that are resolved for relation_member tables.
+	relationMemberDepth int
+	// hugeRelationMode is the handling of relations with more than
+	// maxRelationMembers members.
+	hugeRelationMode HugeRelationMode
+	// hugeRelationTolerance is the simplify tolerance for
+	// HugeRelationSimplify.
+	hugeRelationTolerance float64
 }
 
+// HugeRelationMode is the handling of the multipolygons of relations with
+// more than SetMaxRelationMembers members.
+type HugeRelationMode string
+
+const (
+	// HugeRelationSkip does not build the multipolygons of huge relations.
+	HugeRelationSkip HugeRelationMode = "skip"
+	// HugeRelationBuild builds the multipolygons of huge relations with
+	// one member way in memory at a time (plus all open rings).
+	HugeRelationBuild HugeRelationMode = "build"
+	// HugeRelationSimplify is like HugeRelationBuild, but simplifies each
+	// ring as soon as it is closed.
+	HugeRelationSimplify HugeRelationMode = "simplify"
+)
+
 func (writer *OsmElemWriter) SetLimiter(limiter *limit.Limiter) {
 	writer.limiter = limiter
 }
 
+// SetMaxRelationMembers handles all relations with more than n members
+// as huge relations (see SetHugeRelationMode). Building the geometries of
+// other relations requires all member ways in memory at once.
+func (writer *OsmElemWriter) SetMaxRelationMembers(n int) {
+	writer.maxRelationMembers = n
+}
+
+// SetHugeRelationMode sets the handling of huge relations. Relation and
+// relation_member tables are filled in all modes. tolerance is used for
+// HugeRelationSimplify, a default for the SRID is used if it is 0.
+func (writer *OsmElemWriter) SetHugeRelationMode(mode HugeRelationMode, tolerance float64) {
+	if mode == "" {
+		mode = HugeRelationSkip
+	}
+	if tolerance == 0 {
+		tolerance = 10 // 10m
+		if writer.srid == 4326 {
+			tolerance = 1e-4 // ~10m
+		}
+	}
+	writer.hugeRelationMode = mode
+	writer.hugeRelationTolerance = tolerance
+}
+
+// SetRelationMemberDepth resolves the geometries of member relations for
+// relation_member tables up to n levels. Member relations have an empty
+// geometry if n is 0.
+func (writer *OsmElemWriter) SetRelationMemberDepth(n int) {
+	writer.relationMemberDepth = n
+}
+
 func (writer *OsmElemWriter) EnableConcurrent() {
 	writer.concurrent = true
 }
 
+// SetConcurrency sets the number of goroutines for EnableConcurrent. The
+// number of CPUs is used if n is 0.
+func (writer *OsmElemWriter) SetConcurrency(n int) {
+	writer.concurrency = n
+}
+
 func (writer *OsmElemWriter) Start() {
 	concurrency := 1
 	if writer.concurrent {
-		concurrency = runtime.NumCPU()
+		concurrency = writer.concurrency
+		if concurrency <= 0 {
+			concurrency = runtime.NumCPU()
+		}
 	}
 	for i := 0; i < concurrency; i++ {
 		writer.wg.Add(1)
@@ -61,6 +132,14 @@ func (writer *OsmElemWriter) Wait() {
 	writer.wg.Wait()
 }
 
+// reportError passes elements that could not be inserted to the inserter,
+// if it implements database.ErrorReporter.
+func (writer *OsmElemWriter) reportError(elem osm.Element, matches []mapping.Match, err error) {
+	if reporter, ok := writer.inserter.(database.ErrorReporter); ok {
+		reporter.ReportError(elem, matches, err)
+	}
+}
+
 func (writer *OsmElemWriter) NodesToSrid(nodes []osm.Node) {
 	if writer.srid == 4326 {
 		return